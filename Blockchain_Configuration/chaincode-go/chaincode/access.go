@@ -0,0 +1,52 @@
+package chaincode
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// MSP IDs of the organizations in the supply chain
+const (
+	FarmerMSP     = "Org1MSP"
+	WholesalerMSP = "Org2MSP"
+	RetailerMSP   = "Org3MSP"
+)
+
+// clientMSPID returns the MSP ID of the identity that submitted the transaction
+func clientMSPID(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+
+	return mspID, nil
+}
+
+// requireMSP returns an error unless the submitting client belongs to one of the allowed MSPs
+func requireMSP(ctx contractapi.TransactionContextInterface, action string, allowed ...string) error {
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	for _, msp := range allowed {
+		if mspID == msp {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("client from %s is not authorized to %s; only %s may do so", mspID, action, strings.Join(allowed, ", "))
+}
+
+// custodianMSP returns the MSP of the organization holding an asset in the given custody status
+func custodianMSP(status string) string {
+	switch status {
+	case StatusWithRetailer:
+		return RetailerMSP
+	case StatusWithWholesaler:
+		return WholesalerMSP
+	default:
+		return FarmerMSP
+	}
+}
@@ -0,0 +1,113 @@
+package chaincode
+
+import (
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Inventory is what a participant has on hand: the lots it holds with quantity remaining and their totals
+// by variety
+type Inventory struct {
+	OwnerId   string          `json:"OwnerId"`
+	Lots      []*Asset        `json:"Lots"`
+	Varieties []*VarietyTotal `json:"Varieties"`
+	TotalKg   float64         `json:"TotalKg"`
+}
+
+// VarietyTotal adds up the remaining quantity of one variety in kilograms. Lots counted in units without a
+// fixed weight, such as crates, are totalled per unit in OtherUnits instead.
+type VarietyTotal struct {
+	Variety    string             `json:"Variety"`
+	Lots       int                `json:"Lots"`
+	Kg         float64            `json:"Kg"`
+	OtherUnits map[string]float64 `json:"OtherUnits,omitempty" metadata:",optional"`
+}
+
+// GetInventory returns the lots a participant holds that still have quantity remaining, with the kilograms
+// on hand by variety. Recalled lots are not counted. Lots written before owners were stored are included
+// once MigrateAssets has indexed them.
+func (s *SmartContract) GetInventory(ctx contractapi.TransactionContextInterface, ownerId string) (*Inventory, error) {
+	assets, err := getAssetsByIndex(ctx, ownerIndex, ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	inventory := &Inventory{
+		OwnerId:   ownerId,
+		Lots:      []*Asset{},
+		Varieties: []*VarietyTotal{},
+	}
+	varieties := make(map[string]*VarietyTotal)
+	for _, asset := range assets {
+		if !isCustodyStatus(asset.Status) || asset.RemainingQuantity <= 0 {
+			continue
+		}
+		inventory.Lots = append(inventory.Lots, asset)
+
+		variety, ok := varieties[asset.Variety]
+		if !ok {
+			variety = &VarietyTotal{Variety: asset.Variety}
+			varieties[asset.Variety] = variety
+			inventory.Varieties = append(inventory.Varieties, variety)
+		}
+		variety.Lots++
+		kg, ok := kilograms(asset.RemainingQuantity, asset.Unit)
+		if !ok {
+			if variety.OtherUnits == nil {
+				variety.OtherUnits = make(map[string]float64)
+			}
+			variety.OtherUnits[asset.Unit] += asset.RemainingQuantity
+			continue
+		}
+		variety.Kg += kg
+		inventory.TotalKg += kg
+	}
+	sort.Slice(inventory.Varieties, func(i, j int) bool {
+		return inventory.Varieties[i].Variety < inventory.Varieties[j].Variety
+	})
+
+	return inventory, nil
+}
+
+// takeCustody records the participant now holding a lot and the organization acting for it
+func takeCustody(asset *Asset, ownerID, mspID string) {
+	asset.CurrentOwnerId = ownerID
+	asset.CurrentOwnerMSP = mspID
+}
+
+// releaseCustody records that a lot has left the supply chain, through a sale, a split or merge, or
+// destruction, so nobody holds any of it
+func releaseCustody(asset *Asset) {
+	asset.CurrentOwnerId = ""
+	asset.CurrentOwnerMSP = ""
+	asset.RemainingQuantity = 0
+}
+
+// deriveCustody works out the holder and remaining quantity from the status and party fields. It is used for
+// records written before they were stored.
+func deriveCustody(asset *Asset) {
+	holding := asset.Status
+	if holding == StatusRecalled {
+		holding = deriveStatus(asset)
+	}
+	if !isCustodyStatus(holding) {
+		releaseCustody(asset)
+		return
+	}
+
+	takeCustody(asset, partyID(asset, holding), custodianMSP(holding))
+	asset.RemainingQuantity = asset.Quantity
+}
+
+// partyID returns the ID of the participant that holds a lot in a custody status
+func partyID(asset *Asset, status string) string {
+	switch status {
+	case StatusWithRetailer:
+		return asset.RetailerId
+	case StatusWithWholesaler:
+		return asset.WholesalerId
+	default:
+		return asset.FarmerId
+	}
+}
@@ -0,0 +1,181 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Names of the chaincode events emitted when assets change. Fabric keeps one event per transaction, so
+// transactions that touch several assets emit a single event naming the others in RelatedIDs.
+const (
+	EventLedgerInitialized      = "LedgerInitialized"
+	EventAssetCreated           = "AssetCreated"
+	EventHarvestUpdated         = "HarvestUpdated"
+	EventWholesalePurchased     = "WholesalePurchased"
+	EventRetailPurchased        = "RetailPurchased"
+	EventAssetSold              = "AssetSold"
+	EventAssetConsumed          = "AssetConsumed"
+	EventAssetRecalled          = "AssetRecalled"
+	EventAssetDestroyed         = "AssetDestroyed"
+	EventAssetStatusChanged     = "AssetStatusChanged"
+	EventAssetSplit             = "AssetSplit"
+	EventAssetsMerged           = "AssetsMerged"
+	EventAssetsMigrated         = "AssetsMigrated"
+	EventInspectionAdded        = "InspectionAdded"
+	EventAssetFlagged           = "AssetFlagged"
+	EventTelemetryRecorded      = "TelemetryRecorded"
+	EventColdChainBreach        = "ColdChainBreach"
+	EventParticipantRegistered  = "ParticipantRegistered"
+	EventParticipantUpdated     = "ParticipantUpdated"
+	EventParticipantDeactivated = "ParticipantDeactivated"
+	EventTransferOffered        = "TransferOffered"
+	EventTransferAccepted       = "TransferAccepted"
+	EventTransferRejected       = "TransferRejected"
+	EventSaleRecorded           = "SaleRecorded"
+	EventSalesRecorded          = "SalesRecorded"
+	EventShipmentCreated        = "ShipmentCreated"
+	EventShipmentDispatched     = "ShipmentDispatched"
+	EventShipmentReceived       = "ShipmentReceived"
+	EventShipmentCancelled      = "ShipmentCancelled"
+)
+
+// statusEvents names the event for a status change made through UpdateAssetStatus
+var statusEvents = map[string]string{
+	StatusSold:      EventAssetSold,
+	StatusConsumed:  EventAssetConsumed,
+	StatusDestroyed: EventAssetDestroyed,
+}
+
+// untrackedFields are stamped on every write and left out of ChangedFields
+var untrackedFields = map[string]bool{
+	"LastUpdatedBy": true,
+	"DocType":       true,
+}
+
+// AssetEvent is the payload of every asset chaincode event. ChangedFields holds the new value of each
+// asset field the transaction changed, keyed by its ledger name.
+type AssetEvent struct {
+	Type          string                 `json:"Type"`
+	AssetID       string                 `json:"AssetID"`
+	RelatedIDs    []string               `json:"RelatedIDs,omitempty"`
+	ChangedFields map[string]interface{} `json:"ChangedFields"`
+	MSPID         string                 `json:"MSPID"`
+	TxID          string                 `json:"TxID"`
+	Timestamp     time.Time              `json:"Timestamp"`
+}
+
+// emitAssetEvent sets the chaincode event for a transaction that writes an asset. It must be called before
+// the asset is written so the changes are worked out against the stored version.
+func emitAssetEvent(ctx contractapi.TransactionContextInterface, eventType string, asset *Asset, relatedIDs ...string) error {
+	previous, err := readStoredAsset(ctx, asset.ID)
+	if err != nil {
+		return err
+	}
+	changes, err := changedFields(previous, asset)
+	if err != nil {
+		return err
+	}
+
+	return setEvent(ctx, &AssetEvent{
+		Type:          eventType,
+		AssetID:       asset.ID,
+		RelatedIDs:    relatedIDs,
+		ChangedFields: changes,
+	})
+}
+
+// setEvent stamps an event with the invoking MSP and transaction details and sets it on the transaction
+func setEvent(ctx contractapi.TransactionContextInterface, event *AssetEvent) error {
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+	event.MSPID = mspID
+	event.TxID = ctx.GetStub().GetTxID()
+	event.Timestamp = timestamp.AsTime()
+	if event.ChangedFields == nil {
+		event.ChangedFields = map[string]interface{}{}
+	}
+
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().SetEvent(event.Type, eventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set %s event: %v", event.Type, err)
+	}
+
+	return nil
+}
+
+// changedFields compares two versions of an asset field by field and returns the new values of the fields
+// that differ. Every set field counts as changed when there is no previous version.
+func changedFields(previous, asset *Asset) (map[string]interface{}, error) {
+	before, err := assetFields(previous)
+	if err != nil {
+		return nil, err
+	}
+	after, err := assetFields(asset)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]interface{})
+	for name, value := range after {
+		if untrackedFields[name] || reflect.DeepEqual(before[name], value) {
+			continue
+		}
+		if previous == nil && isZeroField(value) {
+			continue
+		}
+		changes[name] = value
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok && !untrackedFields[name] {
+			changes[name] = nil
+		}
+	}
+
+	return changes, nil
+}
+
+// assetFields returns the ledger representation of an asset as a field map
+func assetFields(asset *Asset) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if asset == nil {
+		return fields, nil
+	}
+
+	assetJSON, err := json.Marshal(asset)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(assetJSON, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+func isZeroField(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case float64:
+		return v == 0
+	default:
+		return false
+	}
+}
@@ -0,0 +1,91 @@
+package chaincode
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// writerObjectType is the composite key object type recording the MSP that invoked each write of an asset,
+// keyed by asset and transaction ID
+const writerObjectType = "writer"
+
+// HistoryEntry is one modification of an asset as recorded on the ledger. MSPID is the organization of the
+// client that submitted the transaction; it is empty for modifications made before writers were recorded.
+type HistoryEntry struct {
+	TxID      string    `json:"TxID"`
+	Timestamp time.Time `json:"Timestamp"`
+	IsDelete  bool      `json:"IsDelete"`
+	MSPID     string    `json:"MSPID"`
+	Asset     *Asset    `json:"Asset,omitempty" metadata:",optional"`
+}
+
+// GetAssetHistory returns every recorded modification of an asset with the transaction,
+// time and invoking MSP that made it. The MSP is taken from the writer recorded by the chaincode for the
+// transaction, not from the asset's LastUpdatedBy field, so older modifications have no MSP ID.
+func (s *SmartContract) GetAssetHistory(ctx contractapi.TransactionContextInterface, id string) ([]*HistoryEntry, error) {
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history of asset %s: %v", id, err)
+	}
+	defer resultsIterator.Close()
+
+	var history []*HistoryEntry
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		entry := HistoryEntry{
+			TxID:     modification.TxId,
+			IsDelete: modification.IsDelete,
+		}
+		if modification.Timestamp != nil {
+			entry.Timestamp = modification.Timestamp.AsTime()
+		}
+		if !modification.IsDelete && len(modification.Value) > 0 {
+			asset, err := unmarshalAsset(modification.Value)
+			if err != nil {
+				return nil, err
+			}
+			entry.Asset = asset
+		}
+		entry.MSPID, err = readWriter(ctx, id, modification.TxId)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, &entry)
+	}
+
+	return history, nil
+}
+
+// recordWriter records the MSP of the client invoking the current transaction as the writer of an asset
+func recordWriter(ctx contractapi.TransactionContextInterface, assetID, mspID string) error {
+	key, err := ctx.GetStub().CreateCompositeKey(writerObjectType, []string{assetID, ctx.GetStub().GetTxID()})
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(key, []byte(mspID))
+	if err != nil {
+		return fmt.Errorf("failed to record the writer of asset %s: %v", assetID, err)
+	}
+
+	return nil
+}
+
+// readWriter returns the MSP recorded as the writer of an asset in a transaction, or "" if none was recorded
+func readWriter(ctx contractapi.TransactionContextInterface, assetID, txID string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(writerObjectType, []string{assetID, txID})
+	if err != nil {
+		return "", err
+	}
+	mspID, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the writer of asset %s: %v", assetID, err)
+	}
+
+	return string(mspID), nil
+}
@@ -0,0 +1,161 @@
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key object types indexing assets by the parties and batch they belong to
+const (
+	farmerIndex     = "farmer~asset"
+	wholesalerIndex = "wholesaler~asset"
+	retailerIndex   = "retailer~asset"
+	ownerIndex      = "owner~asset"
+	batchIndex      = "batch~asset"
+)
+
+// indexValue is stored under every index key; only the key itself carries information
+var indexValue = []byte{0x00}
+
+// assetIndexKeys returns the composite index keys an asset should be reachable under
+func assetIndexKeys(ctx contractapi.TransactionContextInterface, asset *Asset) ([]string, error) {
+	attributes := []struct{ index, value string }{
+		{farmerIndex, asset.FarmerId},
+		{wholesalerIndex, asset.WholesalerId},
+		{retailerIndex, asset.RetailerId},
+		{ownerIndex, asset.CurrentOwnerId},
+		{batchIndex, asset.BatchNo},
+	}
+
+	var keys []string
+	for _, attribute := range attributes {
+		if attribute.value == "" {
+			continue
+		}
+		key, err := ctx.GetStub().CreateCompositeKey(attribute.index, []string{attribute.value, asset.ID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s index key for asset %s: %v", attribute.index, asset.ID, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// updateAssetIndexes removes index entries the previous version of an asset no longer matches
+// and writes the entries for its new version
+func updateAssetIndexes(ctx contractapi.TransactionContextInterface, previous, asset *Asset) error {
+	keys, err := assetIndexKeys(ctx, asset)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]bool)
+	for _, key := range keys {
+		current[key] = true
+	}
+
+	if previous != nil {
+		staleKeys, err := assetIndexKeys(ctx, previous)
+		if err != nil {
+			return err
+		}
+		for _, key := range staleKeys {
+			if current[key] {
+				continue
+			}
+			err = ctx.GetStub().DelState(key)
+			if err != nil {
+				return fmt.Errorf("failed to delete index entry for asset %s: %v", asset.ID, err)
+			}
+		}
+	}
+
+	for _, key := range keys {
+		err = ctx.GetStub().PutState(key, indexValue)
+		if err != nil {
+			return fmt.Errorf("failed to put index entry for asset %s: %v", asset.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// hasIndexEntries reports whether every index entry of an asset is present in the world state
+func hasIndexEntries(ctx contractapi.TransactionContextInterface, asset *Asset) (bool, error) {
+	keys, err := assetIndexKeys(ctx, asset)
+	if err != nil {
+		return false, err
+	}
+	for _, key := range keys {
+		value, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return false, err
+		}
+		if value == nil {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// GetAssetsByFarmer returns the assets harvested by a farmer
+func (s *SmartContract) GetAssetsByFarmer(ctx contractapi.TransactionContextInterface, farmerId string) ([]*Asset, error) {
+	return getAssetsByIndex(ctx, farmerIndex, farmerId)
+}
+
+// GetAssetsByWholesaler returns the assets bought by a wholesaler
+func (s *SmartContract) GetAssetsByWholesaler(ctx contractapi.TransactionContextInterface, wholesalerId string) ([]*Asset, error) {
+	return getAssetsByIndex(ctx, wholesalerIndex, wholesalerId)
+}
+
+// GetAssetsByRetailer returns the assets bought by a retailer
+func (s *SmartContract) GetAssetsByRetailer(ctx contractapi.TransactionContextInterface, retailerId string) ([]*Asset, error) {
+	return getAssetsByIndex(ctx, retailerIndex, retailerId)
+}
+
+// GetAssetsByBatch returns the assets belonging to a batch
+func (s *SmartContract) GetAssetsByBatch(ctx contractapi.TransactionContextInterface, batchNo string) ([]*Asset, error) {
+	return getAssetsByIndex(ctx, batchIndex, batchNo)
+}
+
+// getAssetsByIndex looks up the assets stored under an index for the given value
+func getAssetsByIndex(ctx contractapi.TransactionContextInterface, index, value string) ([]*Asset, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(index, []string{value})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var assets []*Asset
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(attributes) != 2 {
+			return nil, fmt.Errorf("malformed %s index key %q", index, queryResponse.Key)
+		}
+
+		assetJSON, err := ctx.GetStub().GetState(attributes[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read asset %s from world state: %v", attributes[1], err)
+		}
+		if assetJSON == nil {
+			continue
+		}
+		asset, err := unmarshalAsset(assetJSON)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+
+	return assets, nil
+}
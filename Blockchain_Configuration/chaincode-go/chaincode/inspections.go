@@ -0,0 +1,280 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// inspectionObjectType is the composite key object type of inspection records
+const inspectionObjectType = "inspection"
+
+// Measurements an inspection may record, keyed by the names used in Inspection.Measurements
+const (
+	MeasurementBrix             = "brix"
+	MeasurementFirmness         = "firmness"
+	MeasurementDefectPercent    = "defectPercent"
+	MeasurementPesticideResidue = "pesticideResidue"
+)
+
+// Inspection is the result of a quality inspection of a lot. It is stored under its own composite key
+// per asset, so the asset record does not grow with every inspection. Measurements holds only the
+// results that were taken.
+type Inspection struct {
+	ID           string             `json:"ID"`
+	AssetID      string             `json:"AssetID"`
+	Stage        string             `json:"Stage"`
+	Grade        string             `json:"Grade"`
+	Measurements map[string]float64 `json:"Measurements"`
+	Notes        string             `json:"Notes"`
+	InspectorMSP string             `json:"InspectorMSP"`
+	Timestamp    time.Time          `json:"Timestamp"`
+	Breaches     []string           `json:"Breaches,omitempty" metadata:",optional"`
+}
+
+// InspectionRecord is the payload an organization submits to record an inspection. Brix is in °Bx,
+// firmness in newtons, defects as a percentage of the lot and pesticide residue in mg/kg.
+type InspectionRecord struct {
+	ID               string   `json:"id"`
+	Grade            string   `json:"grade"`
+	Brix             *float64 `json:"brix"`
+	Firmness         *float64 `json:"firmness"`
+	DefectPercent    *float64 `json:"defectPercent"`
+	PesticideResidue *float64 `json:"pesticideResidue"`
+	Notes            string   `json:"notes"`
+}
+
+// InspectionThresholds are the limits inspection results are checked against. A limit of zero is not checked.
+type InspectionThresholds struct {
+	MinBrix             float64 `json:"minBrix"`
+	MinFirmness         float64 `json:"minFirmness"`
+	MaxDefectPercent    float64 `json:"maxDefectPercent"`
+	MaxPesticideResidue float64 `json:"maxPesticideResidue"`
+}
+
+// inspectionThresholds are the limits every inspection is checked against. The residue limit is the default
+// maximum residue level for pesticides without a specific limit. They are part of the chaincode rather than
+// a setting any organization can change, so the farmer whose lots are inspected cannot relax them; changing
+// them takes a chaincode upgrade approved by the channel's organizations.
+var inspectionThresholds = InspectionThresholds{
+	MinBrix:             3.5,
+	MaxDefectPercent:    10,
+	MaxPesticideResidue: 0.01,
+}
+
+// AddInspection records a quality inspection of a lot, made by the client's organization at the lot's current
+// custody stage. If a result falls outside the inspection thresholds the breaches are kept on the inspection
+// and added to the lot's QualityFlags.
+func (s *SmartContract) AddInspection(ctx contractapi.TransactionContextInterface, inspectionJSON string) (*Inspection, error) {
+	var record InspectionRecord
+	err := decodePayload(inspectionJSON, &record)
+	if err != nil {
+		return nil, err
+	}
+	err = record.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	asset, err := s.ReadAsset(ctx, record.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !isCustodyStatus(asset.Status) {
+		return nil, fmt.Errorf("the asset %s is %s and can no longer be inspected", asset.ID, asset.Status)
+	}
+	err = requireMSP(ctx, "inspect asset "+asset.ID, FarmerMSP, WholesalerMSP, RetailerMSP)
+	if err != nil {
+		return nil, err
+	}
+
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+	inspection := &Inspection{
+		ID:           ctx.GetStub().GetTxID(),
+		AssetID:      asset.ID,
+		Stage:        asset.Status,
+		Grade:        record.Grade,
+		Measurements: record.measurements(),
+		Notes:        record.Notes,
+		InspectorMSP: mspID,
+		Timestamp:    timestamp.AsTime(),
+	}
+	inspection.Breaches = inspectionThresholds.check(inspection)
+
+	key, err := inspectionKey(ctx, inspection.AssetID, inspection.ID)
+	if err != nil {
+		return nil, err
+	}
+	storedJSON, err := json.Marshal(inspection)
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.GetStub().PutState(key, storedJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to put inspection of asset %s: %v", asset.ID, err)
+	}
+
+	if len(inspection.Breaches) == 0 {
+		err = setEvent(ctx, &AssetEvent{Type: EventInspectionAdded, AssetID: asset.ID})
+		if err != nil {
+			return nil, err
+		}
+		return inspection, nil
+	}
+
+	for _, breach := range inspection.Breaches {
+		addQualityFlag(asset, "inspection "+inspection.ID+": "+breach)
+	}
+	err = emitAssetEvent(ctx, EventAssetFlagged, asset)
+	if err != nil {
+		return nil, err
+	}
+	err = putAsset(ctx, asset)
+	if err != nil {
+		return nil, err
+	}
+
+	return inspection, nil
+}
+
+// GetInspections returns the inspections recorded for an asset, oldest first
+func (s *SmartContract) GetInspections(ctx contractapi.TransactionContextInterface, id string) ([]*Inspection, error) {
+	exists, err := s.AssetExists(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the asset %s does not exist", id)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(inspectionObjectType, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	inspections := []*Inspection{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var inspection Inspection
+		err = json.Unmarshal(queryResponse.Value, &inspection)
+		if err != nil {
+			return nil, err
+		}
+		inspections = append(inspections, &inspection)
+	}
+	sort.SliceStable(inspections, func(i, j int) bool {
+		return inspections[i].Timestamp.Before(inspections[j].Timestamp)
+	})
+
+	return inspections, nil
+}
+
+// GetInspectionThresholds returns the limits inspections are currently checked against
+func (s *SmartContract) GetInspectionThresholds(ctx contractapi.TransactionContextInterface) (*InspectionThresholds, error) {
+	thresholds := inspectionThresholds
+	return &thresholds, nil
+}
+
+// check returns a description of every result of an inspection that falls outside the thresholds
+func (t *InspectionThresholds) check(inspection *Inspection) []string {
+	limits := []struct {
+		measurement string
+		limit       float64
+		minimum     bool
+		format      string
+	}{
+		{MeasurementBrix, t.MinBrix, true, "Brix %g is below the minimum of %g"},
+		{MeasurementFirmness, t.MinFirmness, true, "firmness %g N is below the minimum of %g N"},
+		{MeasurementDefectPercent, t.MaxDefectPercent, false, "defects of %g%% exceed the maximum of %g%%"},
+		{MeasurementPesticideResidue, t.MaxPesticideResidue, false, "pesticide residue of %g mg/kg exceeds the maximum of %g mg/kg"},
+	}
+
+	var breaches []string
+	for _, l := range limits {
+		value, measured := inspection.Measurements[l.measurement]
+		if !measured || l.limit == 0 {
+			continue
+		}
+		if (l.minimum && value < l.limit) || (!l.minimum && value > l.limit) {
+			breaches = append(breaches, fmt.Sprintf(l.format, value, l.limit))
+		}
+	}
+
+	return breaches
+}
+
+// measurements collects the results an inspection record carries, keyed by measurement name
+func (r *InspectionRecord) measurements() map[string]float64 {
+	measurements := make(map[string]float64)
+	for name, value := range map[string]*float64{
+		MeasurementBrix:             r.Brix,
+		MeasurementFirmness:         r.Firmness,
+		MeasurementDefectPercent:    r.DefectPercent,
+		MeasurementPesticideResidue: r.PesticideResidue,
+	} {
+		if value != nil {
+			measurements[name] = *value
+		}
+	}
+
+	return measurements
+}
+
+// validate checks that an inspection record names a lot, carries at least one result and that its
+// measurements are within physical bounds
+func (r *InspectionRecord) validate() error {
+	if r.ID == "" {
+		return fmt.Errorf("invalid payload: id is required")
+	}
+	if r.Grade == "" && r.Brix == nil && r.Firmness == nil && r.DefectPercent == nil && r.PesticideResidue == nil {
+		return fmt.Errorf("invalid payload: an inspection needs a grade or at least one measurement")
+	}
+	measurements := []struct {
+		name  string
+		value *float64
+	}{
+		{MeasurementBrix, r.Brix},
+		{MeasurementFirmness, r.Firmness},
+		{MeasurementDefectPercent, r.DefectPercent},
+		{MeasurementPesticideResidue, r.PesticideResidue},
+	}
+	for _, measurement := range measurements {
+		if measurement.value != nil && *measurement.value < 0 {
+			return fmt.Errorf("invalid payload: %s must not be negative", measurement.name)
+		}
+	}
+	if r.DefectPercent != nil && *r.DefectPercent > 100 {
+		return fmt.Errorf("invalid payload: defectPercent must not exceed 100")
+	}
+
+	return nil
+}
+
+// addQualityFlag adds a reason to the quality flags of an asset unless it is already there
+func addQualityFlag(asset *Asset, flag string) {
+	for _, existing := range asset.QualityFlags {
+		if existing == flag {
+			return
+		}
+	}
+	asset.QualityFlags = append(asset.QualityFlags, flag)
+}
+
+func inspectionKey(ctx contractapi.TransactionContextInterface, assetID, inspectionID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(inspectionObjectType, []string{assetID, inspectionID})
+}
@@ -0,0 +1,73 @@
+package chaincode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestAddInspection(t *testing.T) {
+	tests := []struct {
+		name         string
+		client       testIdentity
+		inspection   string
+		wantErr      string
+		wantBreaches int
+	}{
+		{name: "results within the thresholds", client: farmerClient, inspection: `{"id": "A", "grade": "A", "brix": 4.2, "defectPercent": 3}`},
+		{name: "results outside the thresholds", client: farmerClient, inspection: `{"id": "A", "brix": 2.8, "defectPercent": 12, "pesticideResidue": 0.02}`, wantBreaches: 3},
+		{name: "grade only", client: wholesalerClient, inspection: `{"id": "A", "grade": "B"}`},
+		{name: "nothing recorded", client: farmerClient, inspection: `{"id": "A"}`, wantErr: "needs a grade or at least one measurement"},
+		{name: "negative result", client: farmerClient, inspection: `{"id": "A", "firmness": -1}`, wantErr: "firmness must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newTestLedger(t)
+			ledger.harvest("A", 100)
+
+			var inspection *Inspection
+			err := ledger.submit(tt.client, nil, func(ctx contractapi.TransactionContextInterface) error {
+				var err error
+				inspection, err = ledger.contract.AddInspection(ctx, tt.inspection)
+				return err
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(inspection.Breaches) != tt.wantBreaches || inspection.InspectorMSP != tt.client.mspID || inspection.Stage != StatusHarvested {
+				t.Errorf("got inspection by %s at %s with breaches %v", inspection.InspectorMSP, inspection.Stage, inspection.Breaches)
+			}
+			if flags := ledger.asset("A").QualityFlags; len(flags) != tt.wantBreaches {
+				t.Errorf("got quality flags %v, want %d", flags, tt.wantBreaches)
+			}
+		})
+	}
+}
+
+func TestInspectionThresholdsCannotBeChanged(t *testing.T) {
+	ledger := newTestLedger(t)
+
+	message := ledger.invoke(farmerClient, "SetInspectionThresholds", `{"minBrix": 0, "maxDefectPercent": 100, "maxPesticideResidue": 100}`)
+	if !strings.Contains(message, "SetInspectionThresholds not found") {
+		t.Fatalf("got %q from a farmer changing the inspection thresholds, want the call refused", message)
+	}
+
+	var thresholds *InspectionThresholds
+	ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		thresholds, err = ledger.contract.GetInspectionThresholds(ctx)
+		return err
+	})
+	if *thresholds != inspectionThresholds {
+		t.Errorf("got thresholds %+v, want %+v", *thresholds, inspectionThresholds)
+	}
+}
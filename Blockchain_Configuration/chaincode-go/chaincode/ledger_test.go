@@ -0,0 +1,282 @@
+package chaincode
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Participants registered on every test ledger, one per role
+const (
+	testFarmer     = "farmer-1"
+	testWholesaler = "wholesaler-1"
+	testRetailer   = "retailer-1"
+)
+
+// testIdentity is a client submitting transactions, identified by its organization
+type testIdentity struct {
+	mspID string
+}
+
+var (
+	farmerClient     = testIdentity{mspID: FarmerMSP}
+	wholesalerClient = testIdentity{mspID: WholesalerMSP}
+	retailerClient   = testIdentity{mspID: RetailerMSP}
+)
+
+// testLedger runs contract functions against a mock stub, one transaction per call
+type testLedger struct {
+	t        *testing.T
+	stub     *rangeStub
+	contract *SmartContract
+	tx       int
+	creators map[testIdentity][]byte
+	events   []*AssetEvent
+	// elapsed moves the clock of later transactions forward
+	elapsed time.Duration
+}
+
+// rangeStub hides composite keys from range queries, as the peer does
+type rangeStub struct {
+	*shimtest.MockStub
+}
+
+func (stub *rangeStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	iterator, err := stub.MockStub.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	results := &sliceIterator{}
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(kv.Key, compositeKeyNamespace) {
+			results.kvs = append(results.kvs, kv)
+		}
+	}
+
+	return results, nil
+}
+
+// compositeKeyNamespace starts every composite key
+const compositeKeyNamespace = "\x00"
+
+type sliceIterator struct {
+	kvs  []*queryresult.KV
+	next int
+}
+
+func (it *sliceIterator) HasNext() bool { return it.next < len(it.kvs) }
+func (it *sliceIterator) Close() error  { return nil }
+func (it *sliceIterator) Next() (*queryresult.KV, error) {
+	it.next++
+	return it.kvs[it.next-1], nil
+}
+
+// newTestLedger returns a ledger with a farmer, a wholesaler and a retailer registered
+func newTestLedger(t *testing.T) *testLedger {
+	t.Helper()
+	ledger := &testLedger{
+		t:        t,
+		stub:     &rangeStub{shimtest.NewMockStub("toma-trace", nil)},
+		contract: new(SmartContract),
+		creators: make(map[testIdentity][]byte),
+	}
+
+	participants := []struct {
+		client testIdentity
+		id     string
+		role   string
+	}{
+		{farmerClient, testFarmer, RoleFarmer},
+		{wholesalerClient, testWholesaler, RoleWholesaler},
+		{retailerClient, testRetailer, RoleRetailer},
+	}
+	for _, p := range participants {
+		ledger.mustSubmit(p.client, func(ctx contractapi.TransactionContextInterface) error {
+			return ledger.contract.RegisterParticipant(ctx, fmt.Sprintf(`{"id": %q, "role": %q, "name": "%s name"}`, p.id, p.role, p.id))
+		})
+	}
+
+	return ledger
+}
+
+// submit runs fn as one transaction by client with the given transient map and collects the event it sets
+func (ledger *testLedger) submit(client testIdentity, transient map[string][]byte, fn func(contractapi.TransactionContextInterface) error) error {
+	ledger.t.Helper()
+	ledger.tx++
+	txID := fmt.Sprintf("tx%d", ledger.tx)
+	ledger.stub.Creator = ledger.creator(client)
+	ledger.stub.TransientMap = transient
+	ledger.stub.MockTransactionStart(txID)
+	defer ledger.stub.MockTransactionEnd(txID)
+	ledger.stub.TxTimestamp = timestamppb.New(time.Now().Add(ledger.elapsed))
+
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(ledger.stub)
+	clientIdentity, err := cid.New(ledger.stub)
+	if err != nil {
+		ledger.t.Fatalf("failed to read the client identity: %v", err)
+	}
+	ctx.SetClientIdentity(clientIdentity)
+
+	err = fn(ctx)
+	for {
+		select {
+		case event := <-ledger.stub.ChaincodeEventsChannel:
+			if err == nil {
+				var assetEvent AssetEvent
+				if jsonErr := json.Unmarshal(event.Payload, &assetEvent); jsonErr != nil {
+					ledger.t.Fatalf("event %s has an unreadable payload: %v", event.EventName, jsonErr)
+				}
+				ledger.events = append(ledger.events, &assetEvent)
+			}
+		default:
+			return err
+		}
+	}
+}
+
+// invoke calls a transaction function by name through the contract's chaincode, as a peer would, and
+// returns the error message of a failed call
+func (ledger *testLedger) invoke(client testIdentity, function string, args ...string) string {
+	ledger.t.Helper()
+	chaincode, err := contractapi.NewChaincode(ledger.contract)
+	if err != nil {
+		ledger.t.Fatalf("failed to create the chaincode: %v", err)
+	}
+	stub := shimtest.NewMockStub("toma-trace", chaincode)
+	stub.Creator = ledger.creator(client)
+
+	invokeArgs := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		invokeArgs = append(invokeArgs, []byte(arg))
+	}
+	ledger.tx++
+	response := stub.MockInvoke(fmt.Sprintf("tx%d", ledger.tx), invokeArgs)
+	if response.Status == shim.OK {
+		return ""
+	}
+	return response.Message
+}
+
+// mustSubmit runs a transaction that is expected to succeed
+func (ledger *testLedger) mustSubmit(client testIdentity, fn func(contractapi.TransactionContextInterface) error) {
+	ledger.t.Helper()
+	if err := ledger.submit(client, nil, fn); err != nil {
+		ledger.t.Fatalf("transaction %d failed: %v", ledger.tx, err)
+	}
+}
+
+// lastEvent returns the type of the event set by the latest transaction that set one
+func (ledger *testLedger) lastEvent() string {
+	if len(ledger.events) == 0 {
+		return ""
+	}
+	return ledger.events[len(ledger.events)-1].Type
+}
+
+// harvest creates a lot held by the test farmer
+func (ledger *testLedger) harvest(id string, quantity float64) {
+	ledger.t.Helper()
+	ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+		return ledger.contract.CreateAsset(ctx, fmt.Sprintf(`{"id": %q, "farmerId": %q, "variety": "Roma", "batchNo": "B1", "harvestDate": "2024-03-01T06:00:00Z", "quantity": %g, "unit": "kg"}`,
+			id, testFarmer, quantity))
+	})
+}
+
+// transfer offers the whole of a lot to buyerID and has buyer accept it
+func (ledger *testLedger) transfer(seller, buyer testIdentity, assetID, buyerID string) {
+	ledger.t.Helper()
+	quantity := ledger.asset(assetID).RemainingQuantity
+	var offerID string
+	ledger.mustSubmit(seller, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		offerID, err = ledger.contract.OfferTransfer(ctx, fmt.Sprintf(`{"assetId": %q, "buyerId": %q, "quantity": %g}`, assetID, buyerID, quantity))
+		return err
+	})
+	ledger.mustSubmit(buyer, func(ctx contractapi.TransactionContextInterface) error {
+		return ledger.contract.AcceptTransfer(ctx, offerID)
+	})
+}
+
+// asset reads an asset, failing the test if it cannot
+func (ledger *testLedger) asset(id string) *Asset {
+	ledger.t.Helper()
+	var asset *Asset
+	ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		asset, err = ledger.contract.ReadAsset(ctx, id)
+		return err
+	})
+	return asset
+}
+
+// creator returns the serialized identity of a client, with a self-signed certificate made on first use
+func (ledger *testLedger) creator(client testIdentity) []byte {
+	if creator, ok := ledger.creators[client]; ok {
+		return creator
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		ledger.t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(int64(len(ledger.creators) + 1)),
+		Subject:      pkix.Name{CommonName: "User1@" + client.mspID},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		ledger.t.Fatal(err)
+	}
+
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   client.mspID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	if err != nil {
+		ledger.t.Fatal(err)
+	}
+	ledger.creators[client] = creator
+
+	return creator
+}
+
+// priceTransient is the transient map a client sends a price in, with a fresh salt
+func priceTransient(t *testing.T, price int64) map[string][]byte {
+	t.Helper()
+	salt := make([]byte, minSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string][]byte{
+		priceTransientKey: []byte(fmt.Sprintf(`{"price": %d, "currency": "GHS"}`, price)),
+		saltTransientKey:  salt,
+	}
+}
@@ -0,0 +1,76 @@
+package chaincode
+
+import (
+	"fmt"
+)
+
+// Custody lifecycle states of an asset
+const (
+	StatusHarvested      = "Harvested"
+	StatusWithWholesaler = "WithWholesaler"
+	StatusWithRetailer   = "WithRetailer"
+	StatusSold           = "Sold"
+	StatusConsumed       = "Consumed"
+	StatusRecalled       = "Recalled"
+	StatusDestroyed      = "Destroyed"
+)
+
+// statusTransitions lists, for every status, the statuses an asset may move to next
+var statusTransitions = map[string][]string{
+	StatusHarvested:      {StatusWithWholesaler, StatusConsumed, StatusRecalled, StatusDestroyed},
+	StatusWithWholesaler: {StatusWithRetailer, StatusConsumed, StatusRecalled, StatusDestroyed},
+	StatusWithRetailer:   {StatusSold, StatusConsumed, StatusRecalled, StatusDestroyed},
+	StatusSold:           {StatusRecalled},
+	StatusConsumed:       {StatusRecalled},
+	StatusRecalled:       {StatusDestroyed},
+	StatusDestroyed:      {},
+}
+
+// isKnownStatus reports whether status is one of the lifecycle states
+func isKnownStatus(status string) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+// checkTransition returns an error if an asset may not move from one status to another.
+// Staying in the same status is always allowed.
+func checkTransition(id, from, to string) error {
+	if !isKnownStatus(to) {
+		return fmt.Errorf("unknown status %q for asset %s", to, id)
+	}
+	if from == to {
+		return nil
+	}
+	for _, next := range statusTransitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("asset %s cannot move from %s to %s", id, from, to)
+}
+
+// isCustodyStatus reports whether an asset in the given status is still held in the supply chain and may be traded
+func isCustodyStatus(status string) bool {
+	return status == StatusHarvested || status == StatusWithWholesaler || status == StatusWithRetailer
+}
+
+// deriveStatus works out the custody status from which wholesaler and retailer fields are filled in.
+// It is used for records written before the Status field existed.
+func deriveStatus(asset *Asset) string {
+	switch {
+	case hasRetailer(asset):
+		return StatusWithRetailer
+	case hasWholesaler(asset):
+		return StatusWithWholesaler
+	default:
+		return StatusHarvested
+	}
+}
+
+func hasWholesaler(asset *Asset) bool {
+	return asset.WholesalerId != "" || asset.WholesalerName != "" || asset.WholesalerBuyDate != ""
+}
+
+func hasRetailer(asset *Asset) bool {
+	return asset.RetailerId != "" || asset.RetailerName != "" || asset.RetailerBuyDate != ""
+}
@@ -0,0 +1,107 @@
+package chaincode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestCheckTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{StatusHarvested, StatusWithWholesaler, true},
+		{StatusHarvested, StatusWithRetailer, false},
+		{StatusHarvested, StatusHarvested, true},
+		{StatusWithWholesaler, StatusWithRetailer, true},
+		{StatusWithWholesaler, StatusHarvested, false},
+		{StatusWithRetailer, StatusSold, true},
+		{StatusWithWholesaler, StatusSold, false},
+		{StatusSold, StatusRecalled, true},
+		{StatusSold, StatusWithRetailer, false},
+		{StatusConsumed, StatusRecalled, true},
+		{StatusRecalled, StatusDestroyed, true},
+		{StatusRecalled, StatusHarvested, false},
+		{StatusDestroyed, StatusRecalled, false},
+		{StatusHarvested, "Rotten", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			err := checkTransition("A", tt.from, tt.to)
+			if tt.allowed && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.allowed && err == nil {
+				t.Errorf("moving from %s to %s was allowed", tt.from, tt.to)
+			}
+		})
+	}
+}
+
+func TestDeriveStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		asset Asset
+		want  string
+	}{
+		{"farmer only", Asset{FarmerId: "F1"}, StatusHarvested},
+		{"wholesaler named", Asset{FarmerId: "F1", WholesalerName: "Makola Traders"}, StatusWithWholesaler},
+		{"wholesaler bought", Asset{FarmerId: "F1", WholesalerBuyDate: "2024-03-02T00:00:00Z"}, StatusWithWholesaler},
+		{"retailer named", Asset{FarmerId: "F1", WholesalerId: "W1", RetailerId: "R1"}, StatusWithRetailer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deriveStatus(&tt.asset); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateAssetStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  testIdentity
+		status  string
+		wantErr string
+	}{
+		{name: "holder destroys a lot", client: farmerClient, status: StatusDestroyed},
+		{name: "holder marks a lot consumed", client: farmerClient, status: StatusConsumed},
+		{name: "another organization", client: wholesalerClient, status: StatusDestroyed, wantErr: "not authorized"},
+		{name: "recall", client: farmerClient, status: StatusRecalled, wantErr: "must be recalled with RecallAsset"},
+		{name: "custody change", client: farmerClient, status: StatusWithWholesaler, wantErr: "changes hands through OfferTransfer"},
+		{name: "skipped stage", client: farmerClient, status: StatusSold, wantErr: "cannot move from Harvested to Sold"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newTestLedger(t)
+			ledger.harvest("A", 100)
+
+			err := ledger.submit(tt.client, nil, func(ctx contractapi.TransactionContextInterface) error {
+				return ledger.contract.UpdateAssetStatus(ctx, "A", tt.status)
+			})
+			asset := ledger.asset("A")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				if asset.Status != StatusHarvested {
+					t.Errorf("a rejected change left status %s", asset.Status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if asset.Status != tt.status || asset.CurrentOwnerId != "" || asset.RemainingQuantity != 0 {
+				t.Errorf("got status %s, owner %q and %g remaining; want %s with nobody holding it",
+					asset.Status, asset.CurrentOwnerId, asset.RemainingQuantity, tt.status)
+			}
+		})
+	}
+}
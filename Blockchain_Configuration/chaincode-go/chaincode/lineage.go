@@ -0,0 +1,170 @@
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Lineage edge types, from the asset that was divided or combined to the asset it produced
+const (
+	EdgeSplit = "split"
+	EdgeMerge = "merge"
+)
+
+// LineageGraph is the set of assets connected to a root asset through splits and merges
+type LineageGraph struct {
+	RootID    string         `json:"RootID"`
+	Direction string         `json:"Direction"`
+	Nodes     []*LineageNode `json:"Nodes"`
+	Edges     []*LineageEdge `json:"Edges"`
+}
+
+// LineageNode summarizes one asset in a lineage graph
+type LineageNode struct {
+	ID           string  `json:"ID"`
+	FarmerId     string  `json:"FarmerId"`
+	FarmerName   string  `json:"FarmerName"`
+	FarmLocation string  `json:"FarmLocation"`
+	Variety      string  `json:"Variety"`
+	BatchNo      string  `json:"BatchNo"`
+	Quantity     float64 `json:"Quantity"`
+	Unit         string  `json:"Unit"`
+	WholesalerId string  `json:"WholesalerId"`
+	RetailerId   string  `json:"RetailerId"`
+	RetailerName string  `json:"RetailerName"`
+	Status       string  `json:"Status"`
+}
+
+// LineageEdge links an asset to an asset split or merged from it
+type LineageEdge struct {
+	From string `json:"From"`
+	To   string `json:"To"`
+	Type string `json:"Type"`
+}
+
+// GetUpstreamLineage returns the graph of assets an asset was split or merged from, back to the original
+// harvest lots, answering which farms fed a lot
+func (s *SmartContract) GetUpstreamLineage(ctx contractapi.TransactionContextInterface, id string) (*LineageGraph, error) {
+	return s.walkLineage(ctx, id, "upstream", upstreamIDs)
+}
+
+// GetDownstreamLineage returns the graph of assets split or merged from an asset, down to the lots currently
+// held, answering which retail lots contain tomatoes from it
+func (s *SmartContract) GetDownstreamLineage(ctx contractapi.TransactionContextInterface, id string) (*LineageGraph, error) {
+	return s.walkLineage(ctx, id, "downstream", func(asset *Asset) []string { return asset.ChildIDs })
+}
+
+// walkLineage visits every asset reachable from the root through the given links and records the edges between them
+func (s *SmartContract) walkLineage(ctx contractapi.TransactionContextInterface, id, direction string, links func(*Asset) []string) (*LineageGraph, error) {
+	root, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &LineageGraph{
+		RootID:    root.ID,
+		Direction: direction,
+		Nodes:     []*LineageNode{newLineageNode(root)},
+		Edges:     []*LineageEdge{},
+	}
+	visited := map[string]bool{root.ID: true}
+	queue := []*Asset{root}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, linkedID := range links(current) {
+			linked, err := s.ReadAsset(ctx, linkedID)
+			if err != nil {
+				return nil, err
+			}
+
+			if direction == "upstream" {
+				graph.Edges = append(graph.Edges, newLineageEdge(linked, current))
+			} else {
+				graph.Edges = append(graph.Edges, newLineageEdge(current, linked))
+			}
+
+			if visited[linked.ID] {
+				continue
+			}
+			visited[linked.ID] = true
+			graph.Nodes = append(graph.Nodes, newLineageNode(linked))
+			queue = append(queue, linked)
+		}
+	}
+
+	return graph, nil
+}
+
+func newLineageNode(asset *Asset) *LineageNode {
+	return &LineageNode{
+		ID:           asset.ID,
+		FarmerId:     asset.FarmerId,
+		FarmerName:   asset.FarmerName,
+		FarmLocation: asset.FarmLocation,
+		Variety:      asset.Variety,
+		BatchNo:      asset.BatchNo,
+		Quantity:     asset.Quantity,
+		Unit:         asset.Unit,
+		WholesalerId: asset.WholesalerId,
+		RetailerId:   asset.RetailerId,
+		RetailerName: asset.RetailerName,
+		Status:       asset.Status,
+	}
+}
+
+// newLineageEdge links an asset to one produced from it, telling splits from merges by the produced asset's links
+func newLineageEdge(from, to *Asset) *LineageEdge {
+	edgeType := EdgeMerge
+	if to.ParentID == from.ID {
+		edgeType = EdgeSplit
+	}
+
+	return &LineageEdge{From: from.ID, To: to.ID, Type: edgeType}
+}
+
+// GetOriginAssets walks back through splits and merges and returns the original harvest lots an asset
+// was made from, so every contributing farmer can be traced. An original lot is its own origin.
+func (s *SmartContract) GetOriginAssets(ctx contractapi.TransactionContextInterface, id string) ([]*Asset, error) {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var origins []*Asset
+	visited := map[string]bool{asset.ID: true}
+	queue := []*Asset{asset}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		upstream := upstreamIDs(current)
+		if len(upstream) == 0 {
+			origins = append(origins, current)
+			continue
+		}
+		for _, upstreamID := range upstream {
+			if visited[upstreamID] {
+				continue
+			}
+			visited[upstreamID] = true
+
+			next, err := s.ReadAsset(ctx, upstreamID)
+			if err != nil {
+				return nil, err
+			}
+			queue = append(queue, next)
+		}
+	}
+
+	return origins, nil
+}
+
+// upstreamIDs returns the assets an asset was split or merged from
+func upstreamIDs(asset *Asset) []string {
+	if asset.ParentID != "" {
+		return []string{asset.ParentID}
+	}
+
+	return asset.SourceIDs
+}
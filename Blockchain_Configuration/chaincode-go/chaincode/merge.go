@@ -0,0 +1,147 @@
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// MergeRequest is the payload for consolidating several lots into one shipment asset
+type MergeRequest struct {
+	ID        string   `json:"id"`
+	SourceIDs []string `json:"sourceIds"`
+}
+
+// MergeAssets creates a new asset from several lots held by the same party. The new asset records the
+// source asset IDs and the sum of their remaining quantities; details shared by all sources are carried over and the rest
+// are left empty. Quality flags raised on any source carry over. The sources are marked Consumed.
+func (s *SmartContract) MergeAssets(ctx contractapi.TransactionContextInterface, mergeJSON string) error {
+	var merge MergeRequest
+	err := decodePayload(mergeJSON, &merge)
+	if err != nil {
+		return err
+	}
+	if merge.ID == "" {
+		return fmt.Errorf("invalid payload: id is required")
+	}
+	if len(merge.SourceIDs) < 2 {
+		return fmt.Errorf("a merge into asset %s needs at least two source assets", merge.ID)
+	}
+
+	exists, err := s.AssetExists(ctx, merge.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("the asset %s already exists", merge.ID)
+	}
+
+	var sources []*Asset
+	seen := make(map[string]bool)
+	total := 0.0
+	for _, sourceID := range merge.SourceIDs {
+		if seen[sourceID] {
+			return fmt.Errorf("invalid payload: source asset %s is listed more than once", sourceID)
+		}
+		seen[sourceID] = true
+
+		source, err := s.ReadAsset(ctx, sourceID)
+		if err != nil {
+			return err
+		}
+		if !isCustodyStatus(source.Status) {
+			return fmt.Errorf("the asset %s is %s and cannot be merged", source.ID, source.Status)
+		}
+		if len(sources) > 0 && !sameHolder(sources[0], source) {
+			return fmt.Errorf("the assets %s and %s are not held by the same party and cannot be merged", sources[0].ID, source.ID)
+		}
+		if len(sources) > 0 && sources[0].Unit != source.Unit {
+			return fmt.Errorf("the assets %s and %s are measured in different units and cannot be merged", sources[0].ID, source.ID)
+		}
+		err = checkTransition(source.ID, source.Status, StatusConsumed)
+		if err != nil {
+			return err
+		}
+		err = checkNotCommitted(ctx, source.ID)
+		if err != nil {
+			return err
+		}
+
+		if source.RemainingQuantity <= 0 {
+			return fmt.Errorf("the asset %s has no quantity to merge", source.ID)
+		}
+		total += source.RemainingQuantity
+		sources = append(sources, source)
+	}
+
+	err = requireMSP(ctx, "merge assets", custodianMSP(sources[0].Status))
+	if err != nil {
+		return err
+	}
+
+	merged := Asset{
+		ID:                merge.ID,
+		FarmerId:          commonValue(sources, func(a *Asset) string { return a.FarmerId }),
+		FarmerName:        commonValue(sources, func(a *Asset) string { return a.FarmerName }),
+		FarmLocation:      commonValue(sources, func(a *Asset) string { return a.FarmLocation }),
+		Variety:           commonValue(sources, func(a *Asset) string { return a.Variety }),
+		BatchNo:           commonValue(sources, func(a *Asset) string { return a.BatchNo }),
+		HarvestDate:       commonValue(sources, func(a *Asset) string { return a.HarvestDate }),
+		Quantity:          total,
+		RemainingQuantity: total,
+		Unit:              sources[0].Unit,
+		WholesalerId:      commonValue(sources, func(a *Asset) string { return a.WholesalerId }),
+		WholesalerName:    commonValue(sources, func(a *Asset) string { return a.WholesalerName }),
+		WholesalerBuyDate: commonValue(sources, func(a *Asset) string { return a.WholesalerBuyDate }),
+		RetailerId:        commonValue(sources, func(a *Asset) string { return a.RetailerId }),
+		RetailerName:      commonValue(sources, func(a *Asset) string { return a.RetailerName }),
+		RetailerBuyDate:   commonValue(sources, func(a *Asset) string { return a.RetailerBuyDate }),
+		CurrentOwnerId:    sources[0].CurrentOwnerId,
+		CurrentOwnerMSP:   sources[0].CurrentOwnerMSP,
+		Status:            sources[0].Status,
+		SourceIDs:         merge.SourceIDs,
+	}
+	for _, source := range sources {
+		for _, flag := range source.QualityFlags {
+			addQualityFlag(&merged, flag)
+		}
+	}
+	err = emitAssetEvent(ctx, EventAssetsMerged, &merged, merge.SourceIDs...)
+	if err != nil {
+		return err
+	}
+	err = putAsset(ctx, &merged)
+	if err != nil {
+		return err
+	}
+
+	for _, source := range sources {
+		source.Status = StatusConsumed
+		releaseCustody(source)
+		source.ChildIDs = append(source.ChildIDs, merged.ID)
+		err = putAsset(ctx, source)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sameHolder reports whether two assets are in the same custody status and held by the same party
+func sameHolder(a, b *Asset) bool {
+	return a.Status == b.Status && a.CurrentOwnerId == b.CurrentOwnerId
+}
+
+// commonValue returns the value of a field when it is the same on every asset, or the zero value otherwise
+func commonValue[T comparable](assets []*Asset, field func(*Asset) T) T {
+	value := field(assets[0])
+	for _, asset := range assets[1:] {
+		if field(asset) != value {
+			var zero T
+			return zero
+		}
+	}
+
+	return value
+}
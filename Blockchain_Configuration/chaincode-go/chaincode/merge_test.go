@@ -0,0 +1,137 @@
+package chaincode
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestMergeAssets(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(*testLedger)
+		client  testIdentity
+		merge   string
+		wantErr string
+	}{
+		{
+			name:   "lots held by the same farmer",
+			client: farmerClient,
+			merge:  `{"id": "M", "sourceIds": ["A", "B"]}`,
+		},
+		{
+			name:    "a single source",
+			client:  farmerClient,
+			merge:   `{"id": "M", "sourceIds": ["A"]}`,
+			wantErr: "needs at least two source assets",
+		},
+		{
+			name:    "a source listed twice",
+			client:  farmerClient,
+			merge:   `{"id": "M", "sourceIds": ["A", "A"]}`,
+			wantErr: "listed more than once",
+		},
+		{
+			name:    "an ID already in use",
+			client:  farmerClient,
+			merge:   `{"id": "B", "sourceIds": ["A", "B"]}`,
+			wantErr: "the asset B already exists",
+		},
+		{
+			name:    "lots held by different parties",
+			setup:   func(ledger *testLedger) { ledger.transfer(farmerClient, wholesalerClient, "B", testWholesaler) },
+			client:  farmerClient,
+			merge:   `{"id": "M", "sourceIds": ["A", "B"]}`,
+			wantErr: "not held by the same party",
+		},
+		{
+			name: "lots in different units",
+			setup: func(ledger *testLedger) {
+				ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+					return ledger.contract.CreateAsset(ctx, `{"id": "C", "farmerId": "farmer-1", "variety": "Roma", "harvestDate": "2024-03-01T06:00:00Z", "quantity": 4, "unit": "crates"}`)
+				})
+			},
+			client:  farmerClient,
+			merge:   `{"id": "M", "sourceIds": ["A", "C"]}`,
+			wantErr: "measured in different units",
+		},
+		{
+			name:    "an organization not holding the lots",
+			client:  retailerClient,
+			merge:   `{"id": "M", "sourceIds": ["A", "B"]}`,
+			wantErr: "not authorized to merge assets",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newTestLedger(t)
+			ledger.harvest("A", 100)
+			ledger.harvest("B", 50)
+			if tt.setup != nil {
+				tt.setup(ledger)
+			}
+
+			err := ledger.submit(tt.client, nil, func(ctx contractapi.TransactionContextInterface) error {
+				return ledger.contract.MergeAssets(ctx, tt.merge)
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				if source := ledger.asset("A"); source.Status != StatusHarvested || source.RemainingQuantity != 100 {
+					t.Errorf("a rejected merge left source A %s with %g remaining", source.Status, source.RemainingQuantity)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			merged := ledger.asset("M")
+			if merged.Quantity != 150 || merged.RemainingQuantity != 150 || merged.Unit != UnitKg {
+				t.Errorf("got merged lot of %g %s with %g remaining, want 150 kg", merged.Quantity, merged.Unit, merged.RemainingQuantity)
+			}
+			if merged.Status != StatusHarvested || merged.CurrentOwnerId != testFarmer || merged.FarmerId != testFarmer {
+				t.Errorf("got merged lot %s held by %q from farmer %q", merged.Status, merged.CurrentOwnerId, merged.FarmerId)
+			}
+			if !reflect.DeepEqual(merged.SourceIDs, []string{"A", "B"}) {
+				t.Errorf("got source IDs %v", merged.SourceIDs)
+			}
+			for _, id := range []string{"A", "B"} {
+				source := ledger.asset(id)
+				if source.Status != StatusConsumed || source.RemainingQuantity != 0 || !reflect.DeepEqual(source.ChildIDs, []string{"M"}) {
+					t.Errorf("got source %s %s with %g remaining and children %v", id, source.Status, source.RemainingQuantity, source.ChildIDs)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeFromSeveralFarms(t *testing.T) {
+	ledger := newTestLedger(t)
+	ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+		return ledger.contract.RegisterParticipant(ctx, `{"id": "farmer-2", "role": "Farmer", "name": "Second farm", "location": "Wenchi"}`)
+	})
+	ledger.harvest("A", 100)
+	ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+		return ledger.contract.CreateAsset(ctx, `{"id": "B", "farmerId": "farmer-2", "variety": "Roma", "harvestDate": "2024-03-01T06:00:00Z", "quantity": 50, "unit": "kg"}`)
+	})
+
+	// Lots from two farms are held by different farmers, so they are first sold to one wholesaler
+	ledger.transfer(farmerClient, wholesalerClient, "A", testWholesaler)
+	ledger.transfer(farmerClient, wholesalerClient, "B", testWholesaler)
+	ledger.mustSubmit(wholesalerClient, func(ctx contractapi.TransactionContextInterface) error {
+		return ledger.contract.MergeAssets(ctx, `{"id": "M", "sourceIds": ["A", "B"]}`)
+	})
+
+	merged := ledger.asset("M")
+	if merged.FarmerId != "" || merged.FarmLocation != "" {
+		t.Errorf("got farmer %q at %q on a lot from two farms, want them left empty", merged.FarmerId, merged.FarmLocation)
+	}
+	if merged.Variety != "Roma" || merged.WholesalerId != testWholesaler || merged.CurrentOwnerId != testWholesaler {
+		t.Errorf("got shared details %q and %q held by %q", merged.Variety, merged.WholesalerId, merged.CurrentOwnerId)
+	}
+}
@@ -0,0 +1,185 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// MigrationReport summarizes a MigrateAssets run
+type MigrationReport struct {
+	Migrated  int                 `json:"Migrated"`
+	Unchanged int                 `json:"Unchanged"`
+	Failed    []*MigrationFailure `json:"Failed"`
+}
+
+// MigrationFailure records an asset MigrateAssets could not convert, and why
+type MigrationFailure struct {
+	ID     string `json:"ID"`
+	Reason string `json:"Reason"`
+}
+
+// MigrateAssets brings assets written by earlier versions of the chaincode up to date. It converts free-form
+// quantities and dates to their typed form, moves public prices into the farmer–wholesaler collection as
+// harvest prices, stores an explicit status and holder, tags the asset document type for rich queries and writes missing
+// index entries. Prices and quantities that do not name a currency or unit are given defaultCurrency and
+// defaultUnit. Because it writes to the farmer–wholesaler collection, only those organizations may run it.
+// Moving a price requires a random salt in the transient map under "salt", from which each price gets its own.
+// Assets that cannot be converted are left as they are and listed in the report.
+func (s *SmartContract) MigrateAssets(ctx contractapi.TransactionContextInterface, defaultCurrency, defaultUnit string) (*MigrationReport, error) {
+	err := requireMSP(ctx, "migrate assets", collectionMembers[FarmerWholesalerCollection]...)
+	if err != nil {
+		return nil, err
+	}
+	err = validateCurrency(defaultCurrency)
+	if err != nil {
+		return nil, err
+	}
+	defaultUnit, err = normalizeUnit(defaultUnit)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	report := &MigrationReport{Failed: []*MigrationFailure{}}
+	var migratedIDs []string
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		asset, price, changed, err := migrateAsset(queryResponse.Value, defaultCurrency, defaultUnit)
+		if err != nil {
+			report.Failed = append(report.Failed, &MigrationFailure{ID: queryResponse.Key, Reason: err.Error()})
+			continue
+		}
+		if price != nil {
+			price.Salt, err = migrationSalt(ctx, price.ID)
+			if err != nil {
+				return nil, err
+			}
+			asset.PriceHash, err = putPrice(ctx, price)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		indexed, err := hasIndexEntries(ctx, asset)
+		if err != nil {
+			return nil, err
+		}
+		if !changed && indexed {
+			report.Unchanged++
+			continue
+		}
+
+		err = putAsset(ctx, asset)
+		if err != nil {
+			return nil, err
+		}
+		report.Migrated++
+		migratedIDs = append(migratedIDs, asset.ID)
+	}
+
+	if len(migratedIDs) > 0 {
+		err = setEvent(ctx, &AssetEvent{Type: EventAssetsMigrated, RelatedIDs: migratedIDs})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// migrationSalt derives the salt of a migrated price from the salt sent in the transient map and the asset ID,
+// so no two prices share a salt
+func migrationSalt(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the transient map: %v", err)
+	}
+	salt, err := transientSalt(transientMap)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	hash.Write(salt)
+	hash.Write([]byte(id))
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// migrateAsset converts a stored asset to the current format and reports whether anything had to change.
+// A price found on the public asset is returned so it can be moved to private data.
+func migrateAsset(assetJSON []byte, defaultCurrency, defaultUnit string) (*Asset, *PriceRecord, bool, error) {
+	var stored storedAsset
+	err := json.Unmarshal(assetJSON, &stored)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("not a readable asset: %v", err)
+	}
+
+	asset := stored.Asset
+	changed, err := stored.decodeQuantity(&asset)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	price, public, err := stored.decodePrice()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	changed = changed || public
+
+	for _, date := range []*string{&asset.HarvestDate, &asset.WholesalerBuyDate, &asset.RetailerBuyDate} {
+		if *date == "" {
+			continue
+		}
+		if normalized, err := normalizeDate(*date); err == nil && normalized == *date {
+			continue
+		}
+		converted, err := parseLegacyDate(*date)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		*date = converted
+		changed = true
+	}
+
+	if price != nil {
+		if price.Currency == "" {
+			price.Currency = defaultCurrency
+		}
+		err = validateCurrency(price.Currency)
+		if err != nil {
+			return nil, nil, false, err
+		}
+	}
+	if asset.Quantity != 0 && asset.Unit == "" {
+		asset.Unit = defaultUnit
+		changed = true
+	}
+	if asset.Status == "" {
+		asset.Status = deriveStatus(&asset)
+		changed = true
+	}
+	if stored.decodeCustody(&asset) {
+		changed = true
+	}
+	if asset.DocType != assetDocType {
+		changed = true
+	}
+
+	if price != nil {
+		price.Unit = asset.Unit
+	}
+
+	return &asset, price, changed, nil
+}
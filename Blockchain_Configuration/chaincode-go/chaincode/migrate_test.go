@@ -0,0 +1,194 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Assets as written by earlier versions of the chaincode, before prices were private and values were typed
+const (
+	legacyAsset       = `{"ID": "L1", "FarmerId": "farmer-1", "Variety": "Roma", "HarvestDate": "2024-03-01", "Price": "GHS 12.50", "Quantity": "120kg"}`
+	legacyUntyped     = `{"ID": "L2", "FarmerId": "farmer-1", "Variety": "Roma", "HarvestDate": "2024-03-02 06:00:00", "Price": 900, "Quantity": "40"}`
+	legacyUnreadable  = `{"ID": "L3", "FarmerId": "farmer-1", "Variety": "Roma", "HarvestDate": "2024-03-01", "Quantity": "a few crates"}`
+	legacyWholesaler  = `{"ID": "L4", "FarmerId": "farmer-1", "WholesalerId": "wholesaler-1", "WholesalerBuyDate": "2024-03-04", "Quantity": 15, "Unit": "crate"}`
+	legacyUnreadDates = `{"ID": "L5", "FarmerId": "farmer-1", "HarvestDate": "early March", "Quantity": 10}`
+)
+
+func TestMigrateAssets(t *testing.T) {
+	tests := []struct {
+		name          string
+		client        testIdentity
+		stored        []string
+		transient     map[string][]byte
+		wantErr       string
+		wantMigrated  int
+		wantUnchanged int
+		wantFailed    []string
+	}{
+		{
+			name:          "legacy and current assets",
+			client:        farmerClient,
+			stored:        []string{legacyAsset, legacyUntyped, legacyWholesaler},
+			transient:     saltTransient(t),
+			wantMigrated:  3,
+			wantUnchanged: 1,
+			wantFailed:    []string{},
+		},
+		{
+			name:          "assets that cannot be converted",
+			client:        farmerClient,
+			stored:        []string{legacyAsset, legacyUnreadable, legacyUnreadDates},
+			transient:     saltTransient(t),
+			wantMigrated:  1,
+			wantUnchanged: 1,
+			wantFailed:    []string{"L3", "L5"},
+		},
+		{
+			name:          "nothing to move without a salt",
+			client:        wholesalerClient,
+			stored:        []string{legacyWholesaler},
+			wantMigrated:  1,
+			wantUnchanged: 1,
+			wantFailed:    []string{},
+		},
+		{
+			name:    "a price to move without a salt",
+			client:  farmerClient,
+			stored:  []string{legacyAsset},
+			wantErr: "requires a random salt",
+		},
+		{
+			name:      "organization outside the collection",
+			client:    retailerClient,
+			stored:    []string{legacyAsset},
+			transient: saltTransient(t),
+			wantErr:   "not authorized to migrate assets",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newTestLedger(t)
+			ledger.harvest("A", 100)
+			ledger.store(tt.stored...)
+
+			var report *MigrationReport
+			err := ledger.submit(tt.client, tt.transient, func(ctx contractapi.TransactionContextInterface) error {
+				var err error
+				report, err = ledger.contract.MigrateAssets(ctx, "GHS", "kg")
+				return err
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if report.Migrated != tt.wantMigrated || report.Unchanged != tt.wantUnchanged {
+				t.Errorf("got %d migrated and %d unchanged, want %d and %d", report.Migrated, report.Unchanged, tt.wantMigrated, tt.wantUnchanged)
+			}
+			failed := []string{}
+			for _, failure := range report.Failed {
+				failed = append(failed, failure.ID)
+			}
+			if strings.Join(failed, ",") != strings.Join(tt.wantFailed, ",") {
+				t.Errorf("got failures %v, want %v", report.Failed, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestMigrateAssetConversion(t *testing.T) {
+	ledger := newTestLedger(t)
+	ledger.store(legacyAsset, legacyUntyped, legacyWholesaler)
+	err := ledger.submit(farmerClient, saltTransient(t), func(ctx contractapi.TransactionContextInterface) error {
+		_, err := ledger.contract.MigrateAssets(ctx, "GHS", "kg")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		id          string
+		quantity    float64
+		unit        string
+		harvestDate string
+		status      string
+		owner       string
+		price       int64
+	}{
+		{id: "L1", quantity: 120, unit: UnitKg, harvestDate: "2024-03-01T00:00:00Z", status: StatusHarvested, owner: testFarmer, price: 1250},
+		{id: "L2", quantity: 40, unit: UnitKg, harvestDate: "2024-03-02T06:00:00Z", status: StatusHarvested, owner: testFarmer, price: 900},
+		{id: "L4", quantity: 15, unit: UnitCrate, status: StatusWithWholesaler, owner: testWholesaler},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			asset := ledger.asset(tt.id)
+			if asset.Quantity != tt.quantity || asset.RemainingQuantity != tt.quantity || asset.Unit != tt.unit {
+				t.Errorf("got %g of %g %s, want %g %s", asset.RemainingQuantity, asset.Quantity, asset.Unit, tt.quantity, tt.unit)
+			}
+			if tt.harvestDate != "" && asset.HarvestDate != tt.harvestDate {
+				t.Errorf("got harvest date %q, want %q", asset.HarvestDate, tt.harvestDate)
+			}
+			if asset.Status != tt.status || asset.CurrentOwnerId != tt.owner || asset.DocType != assetDocType {
+				t.Errorf("got %s held by %q with document type %q", asset.Status, asset.CurrentOwnerId, asset.DocType)
+			}
+			if tt.price == 0 {
+				if asset.PriceHash != "" {
+					t.Errorf("got price hash %q on an asset without a price", asset.PriceHash)
+				}
+				return
+			}
+
+			key, _ := ledger.stub.CreateCompositeKey(priceObjectType, []string{tt.id, PriceHarvest})
+			priceJSON, _ := ledger.stub.GetPrivateData(FarmerWholesalerCollection, key)
+			sum := sha256.Sum256(priceJSON)
+			if priceJSON == nil || asset.PriceHash != hex.EncodeToString(sum[:]) {
+				t.Errorf("the public price hash does not match the private record %s", priceJSON)
+			}
+			var prices []*PriceRecord
+			ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+				prices, err = ledger.contract.ReadAssetPrices(ctx, tt.id)
+				return err
+			})
+			if len(prices) != 1 || prices[0].Price != tt.price || prices[0].Currency != "GHS" || prices[0].Unit != tt.unit || len(prices[0].Salt) < 2*minSaltLength {
+				t.Errorf("got prices %+v, want %d GHS per %s with a salt", prices, tt.price, tt.unit)
+			}
+		})
+	}
+}
+
+// store writes raw asset documents to world state, bypassing the contract
+func (ledger *testLedger) store(assets ...string) {
+	ledger.t.Helper()
+	ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+		for _, assetJSON := range assets {
+			var stored struct{ ID string }
+			if err := json.Unmarshal([]byte(assetJSON), &stored); err != nil {
+				return err
+			}
+			if err := ctx.GetStub().PutState(stored.ID, []byte(assetJSON)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// saltTransient is a transient map holding only a fresh salt
+func saltTransient(t *testing.T) map[string][]byte {
+	transient := priceTransient(t, 0)
+	delete(transient, priceTransientKey)
+	return transient
+}
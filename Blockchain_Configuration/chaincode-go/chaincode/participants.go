@@ -0,0 +1,288 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// participantObjectType is the composite key object type of registered participants
+const participantObjectType = "participant"
+
+// Roles a participant plays in the supply chain
+const (
+	RoleFarmer     = "Farmer"
+	RoleWholesaler = "Wholesaler"
+	RoleRetailer   = "Retailer"
+)
+
+// Registration states of a participant
+const (
+	ParticipantActive   = "Active"
+	ParticipantInactive = "Inactive"
+)
+
+// roleMSPs maps each role to the organization that registers and acts for participants in that role
+var roleMSPs = map[string]string{
+	RoleFarmer:     FarmerMSP,
+	RoleWholesaler: WholesalerMSP,
+	RoleRetailer:   RetailerMSP,
+}
+
+// Participant is a farmer, wholesaler or retailer that assets may be attributed to. Asset writes only accept
+// the IDs of active participants in the matching role and take the party's name from here.
+type Participant struct {
+	ID       string `json:"ID"`
+	Role     string `json:"Role"`
+	MSPID    string `json:"MSPID"`
+	Name     string `json:"Name"`
+	Location string `json:"Location"`
+	Status   string `json:"Status"`
+}
+
+// ParticipantRecord is the payload an organization submits to register a participant
+type ParticipantRecord struct {
+	ID       string `json:"id"`
+	Role     string `json:"role"`
+	Name     string `json:"name"`
+	Location string `json:"location"`
+}
+
+// ParticipantUpdate is the payload an organization submits to change a participant's details. Empty fields
+// leave the stored value unchanged; setting status to Active reinstates a deactivated participant.
+type ParticipantUpdate struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Location string `json:"location"`
+	Status   string `json:"status"`
+}
+
+// RegisterParticipant adds a participant to the registry. Each organization registers the participants in
+// its own role, so only the farmer organization may register farmers, and so on.
+func (s *SmartContract) RegisterParticipant(ctx contractapi.TransactionContextInterface, participantJSON string) error {
+	var record ParticipantRecord
+	err := decodePayload(participantJSON, &record)
+	if err != nil {
+		return err
+	}
+	if record.ID == "" || record.Name == "" {
+		return fmt.Errorf("invalid payload: id and name are required")
+	}
+	mspID, ok := roleMSPs[record.Role]
+	if !ok {
+		return fmt.Errorf("invalid payload: role must be %s, %s or %s", RoleFarmer, RoleWholesaler, RoleRetailer)
+	}
+	err = requireMSP(ctx, "register "+record.Role+" participants", mspID)
+	if err != nil {
+		return err
+	}
+
+	existing, err := readParticipant(ctx, record.ID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("the participant %s already exists", record.ID)
+	}
+
+	participant := &Participant{
+		ID:       record.ID,
+		Role:     record.Role,
+		MSPID:    mspID,
+		Name:     record.Name,
+		Location: record.Location,
+		Status:   ParticipantActive,
+	}
+	err = putParticipant(ctx, participant)
+	if err != nil {
+		return err
+	}
+
+	return participantEvent(ctx, EventParticipantRegistered, participant)
+}
+
+// UpdateParticipant changes the name, location or status of a participant registered by the client's organization
+func (s *SmartContract) UpdateParticipant(ctx contractapi.TransactionContextInterface, participantJSON string) error {
+	var update ParticipantUpdate
+	err := decodePayload(participantJSON, &update)
+	if err != nil {
+		return err
+	}
+	if update.Status != "" && update.Status != ParticipantActive && update.Status != ParticipantInactive {
+		return fmt.Errorf("invalid payload: status must be %s or %s", ParticipantActive, ParticipantInactive)
+	}
+
+	participant, err := s.GetParticipant(ctx, update.ID)
+	if err != nil {
+		return err
+	}
+	err = requireMSP(ctx, "update participant "+participant.ID, participant.MSPID)
+	if err != nil {
+		return err
+	}
+
+	mergeField(&participant.Name, update.Name)
+	mergeField(&participant.Location, update.Location)
+	mergeField(&participant.Status, update.Status)
+	err = putParticipant(ctx, participant)
+	if err != nil {
+		return err
+	}
+
+	return participantEvent(ctx, EventParticipantUpdated, participant)
+}
+
+// DeactivateParticipant stops a participant registered by the client's organization from being named on
+// further asset writes. Assets already attributed to it are left as they are.
+func (s *SmartContract) DeactivateParticipant(ctx contractapi.TransactionContextInterface, id string) error {
+	participant, err := s.GetParticipant(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = requireMSP(ctx, "deactivate participant "+participant.ID, participant.MSPID)
+	if err != nil {
+		return err
+	}
+	if participant.Status == ParticipantInactive {
+		return fmt.Errorf("the participant %s is already inactive", participant.ID)
+	}
+
+	participant.Status = ParticipantInactive
+	err = putParticipant(ctx, participant)
+	if err != nil {
+		return err
+	}
+
+	return participantEvent(ctx, EventParticipantDeactivated, participant)
+}
+
+// GetParticipant returns a registered participant
+func (s *SmartContract) GetParticipant(ctx contractapi.TransactionContextInterface, id string) (*Participant, error) {
+	participant, err := readParticipant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if participant == nil {
+		return nil, fmt.Errorf("the participant %s does not exist", id)
+	}
+
+	return participant, nil
+}
+
+// activeParticipant returns the participant an asset names for a role, or an error unless it is registered
+// in that role and active
+func activeParticipant(ctx contractapi.TransactionContextInterface, role, id string) (*Participant, error) {
+	name := strings.ToLower(role)
+	if id == "" {
+		return nil, fmt.Errorf("a %s ID is required", name)
+	}
+	participant, err := readParticipant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if participant == nil {
+		return nil, fmt.Errorf("the %s %s is not registered", name, id)
+	}
+	if participant.Role != role {
+		return nil, fmt.Errorf("the participant %s is registered as a %s, not a %s", id, participant.Role, role)
+	}
+	if participant.Status != ParticipantActive {
+		return nil, fmt.Errorf("the %s %s is %s", name, id, strings.ToLower(participant.Status))
+	}
+
+	return participant, nil
+}
+
+// fillFarmer checks the farmer an asset names and copies in the registered name, and the registered location
+// when the lot does not give a field location of its own
+func fillFarmer(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	farmer, err := activeParticipant(ctx, RoleFarmer, asset.FarmerId)
+	if err != nil {
+		return err
+	}
+	asset.FarmerName = farmer.Name
+	if asset.FarmLocation == "" {
+		asset.FarmLocation = farmer.Location
+	}
+
+	return nil
+}
+
+// fillWholesaler checks the wholesaler an asset names and copies in the registered name
+func fillWholesaler(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	wholesaler, err := activeParticipant(ctx, RoleWholesaler, asset.WholesalerId)
+	if err != nil {
+		return err
+	}
+	asset.WholesalerName = wholesaler.Name
+
+	return nil
+}
+
+// fillRetailer checks the retailer an asset names and copies in the registered name
+func fillRetailer(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	retailer, err := activeParticipant(ctx, RoleRetailer, asset.RetailerId)
+	if err != nil {
+		return err
+	}
+	asset.RetailerName = retailer.Name
+
+	return nil
+}
+
+// readParticipant returns the participant registered under an ID, or nil if there is none
+func readParticipant(ctx contractapi.TransactionContextInterface, id string) (*Participant, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(participantObjectType, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	participantJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read participant %s from world state: %v", id, err)
+	}
+	if participantJSON == nil {
+		return nil, nil
+	}
+
+	var participant Participant
+	err = json.Unmarshal(participantJSON, &participant)
+	if err != nil {
+		return nil, err
+	}
+
+	return &participant, nil
+}
+
+func putParticipant(ctx contractapi.TransactionContextInterface, participant *Participant) error {
+	key, err := ctx.GetStub().CreateCompositeKey(participantObjectType, []string{participant.ID})
+	if err != nil {
+		return err
+	}
+	participantJSON, err := json.Marshal(participant)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(key, participantJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put participant %s: %v", participant.ID, err)
+	}
+
+	return nil
+}
+
+// participantEvent sets a registry event carrying the participant's current details
+func participantEvent(ctx contractapi.TransactionContextInterface, eventType string, participant *Participant) error {
+	return setEvent(ctx, &AssetEvent{
+		Type: eventType,
+		ChangedFields: map[string]interface{}{
+			"ID":       participant.ID,
+			"Role":     participant.Role,
+			"MSPID":    participant.MSPID,
+			"Name":     participant.Name,
+			"Location": participant.Location,
+			"Status":   participant.Status,
+		},
+	})
+}
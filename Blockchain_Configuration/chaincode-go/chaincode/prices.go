@@ -0,0 +1,326 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Private data collections shared by trading partners, defined in collections_config.json
+const (
+	FarmerWholesalerCollection   = "farmerWholesalerCollection"
+	WholesalerRetailerCollection = "wholesalerRetailerCollection"
+)
+
+// Kinds of price kept for a lot
+const (
+	PriceHarvest   = "Harvest"
+	PriceWholesale = "Wholesale"
+	PriceRetail    = "Retail"
+)
+
+// priceTransientKey is the transient map entry that carries a price into a transaction
+const priceTransientKey = "price"
+
+// saltTransientKey is the transient map entry that carries the random salt stored with a price. Without a salt
+// the public hash of a price could be matched by hashing every plausible price.
+const saltTransientKey = "salt"
+
+// minSaltLength is the least number of random bytes accepted as a price salt
+const minSaltLength = 32
+
+const priceObjectType = "price"
+
+// collectionMembers lists the organizations that may read and write each collection
+var collectionMembers = map[string][]string{
+	FarmerWholesalerCollection:   {FarmerMSP, WholesalerMSP},
+	WholesalerRetailerCollection: {WholesalerMSP, RetailerMSP},
+}
+
+// priceCollections maps each kind of price to the collection of the two parties to that sale
+var priceCollections = map[string]string{
+	PriceHarvest:   FarmerWholesalerCollection,
+	PriceWholesale: FarmerWholesalerCollection,
+	PriceRetail:    WholesalerRetailerCollection,
+}
+
+// PriceRecord is a price agreed between two trading partners. It is kept in their private data collection and
+// the public asset holds only its hash. Price is per unit, in minor units of Currency (pesewas for GHS).
+// Salt is the hex encoded random salt sent with the price, so the hash cannot be guessed from the price alone.
+type PriceRecord struct {
+	ID       string `json:"ID"`
+	Kind     string `json:"Kind"`
+	Price    int64  `json:"Price"`
+	Currency string `json:"Currency"`
+	Unit     string `json:"Unit"`
+	Salt     string `json:"Salt"`
+}
+
+// PriceInput is the JSON a client puts in the transient map under "price". The salt is sent separately under
+// "salt" as at least 32 random bytes.
+type PriceInput struct {
+	Price    *int64 `json:"price"`
+	Currency string `json:"currency"`
+	salt     []byte
+}
+
+// ReadAssetPrices returns the prices of an asset kept in the collections the client's organization belongs to.
+// The farmer sees the harvest and wholesale prices, the retailer sees the retail price and the wholesaler,
+// party to both sales, sees all three.
+func (s *SmartContract) ReadAssetPrices(ctx contractapi.TransactionContextInterface, id string) ([]*PriceRecord, error) {
+	exists, err := s.AssetExists(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the asset %s does not exist", id)
+	}
+
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := []*PriceRecord{}
+	member := false
+	for _, kind := range []string{PriceHarvest, PriceWholesale, PriceRetail} {
+		collection := priceCollections[kind]
+		if !isCollectionMember(collection, mspID) {
+			continue
+		}
+		member = true
+
+		price, err := readPrice(ctx, collection, id, kind)
+		if err != nil {
+			return nil, err
+		}
+		if price != nil {
+			prices = append(prices, price)
+		}
+	}
+	if !member {
+		return nil, fmt.Errorf("client from %s is not a member of any price collection", mspID)
+	}
+
+	return prices, nil
+}
+
+// QueryPrices runs a CouchDB rich query over the prices of one kind kept in the collection the client's
+// organization shares with its trading partner, such as {"Price": {"$gte": 500, "$lte": 900}, "Currency": "GHS"}.
+// Prices are compared as numbers in minor units. Like QueryAssets the argument is a full query or just the
+// selector. Only prices recorded against assets are returned, not those of open transfer offers.
+func (s *SmartContract) QueryPrices(ctx contractapi.TransactionContextInterface, kind, queryJSON string) ([]*PriceRecord, error) {
+	collection, ok := priceCollections[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown price kind %q", kind)
+	}
+	err := requireMSP(ctx, "query "+kind+" prices", collectionMembers[collection]...)
+	if err != nil {
+		return nil, err
+	}
+	queryString, err := buildPriceQuery(kind, queryJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetPrivateDataQueryResult(collection, queryString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run query: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	prices := []*PriceRecord{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var price PriceRecord
+		err = json.Unmarshal(queryResponse.Value, &price)
+		if err != nil {
+			return nil, err
+		}
+		exists, err := s.AssetExists(ctx, price.ID)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			prices = append(prices, &price)
+		}
+	}
+
+	return prices, nil
+}
+
+// buildPriceQuery turns a caller supplied query or selector into a CouchDB query restricted to one kind of price
+func buildPriceQuery(kind, queryJSON string) (string, error) {
+	var query map[string]interface{}
+	err := json.Unmarshal([]byte(queryJSON), &query)
+	if err != nil {
+		return "", fmt.Errorf("invalid query: %v", err)
+	}
+
+	if _, ok := query["selector"]; !ok {
+		query = map[string]interface{}{"selector": query}
+	}
+	selector, ok := query["selector"].(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("invalid query: selector must be a JSON object")
+	}
+	selector["Kind"] = kind
+
+	queryBytes, err := json.Marshal(query)
+	if err != nil {
+		return "", err
+	}
+
+	return string(queryBytes), nil
+}
+
+// recordPrice stores the price sent in the transient map, if any, in the collection for that kind of price
+// and returns the hash to keep on the public asset. The hash is the SHA-256 of the stored record, salt
+// included, so it matches what GetPrivateDataHash returns to organizations outside the collection.
+// An empty hash means no price was sent.
+func recordPrice(ctx contractapi.TransactionContextInterface, asset *Asset, kind string) (string, error) {
+	input, err := transientPrice(ctx)
+	if err != nil || input == nil {
+		return "", err
+	}
+
+	return putPrice(ctx, &PriceRecord{
+		ID:       asset.ID,
+		Kind:     kind,
+		Price:    *input.Price,
+		Currency: input.Currency,
+		Unit:     asset.Unit,
+		Salt:     hex.EncodeToString(input.salt),
+	})
+}
+
+// transientPrice returns the validated price and salt sent in the transient map, or nil if no price was sent
+func transientPrice(ctx contractapi.TransactionContextInterface) (*PriceInput, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the transient map: %v", err)
+	}
+	priceJSON, ok := transientMap[priceTransientKey]
+	if !ok {
+		return nil, nil
+	}
+
+	var input PriceInput
+	err = decodePayload(string(priceJSON), &input)
+	if err != nil {
+		return nil, err
+	}
+	if input.Price == nil {
+		return nil, fmt.Errorf("invalid payload: price is required")
+	}
+	if *input.Price < 0 {
+		return nil, fmt.Errorf("invalid payload: price must not be negative")
+	}
+	err = validateCurrency(input.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload: %v", err)
+	}
+	input.salt, err = transientSalt(transientMap)
+	if err != nil {
+		return nil, err
+	}
+
+	return &input, nil
+}
+
+// transientSalt returns the salt sent in the transient map, rejecting one too short to hide a price
+func transientSalt(transientMap map[string][]byte) ([]byte, error) {
+	salt, ok := transientMap[saltTransientKey]
+	if !ok {
+		return nil, fmt.Errorf("a price requires a random salt in the transient map under %q", saltTransientKey)
+	}
+	if len(salt) < minSaltLength {
+		return nil, fmt.Errorf("the price salt has %d bytes, at least %d are required", len(salt), minSaltLength)
+	}
+
+	return salt, nil
+}
+
+// applyPrice records the price sent in the transient map, if any, and sets the matching hash on the asset
+func applyPrice(ctx contractapi.TransactionContextInterface, asset *Asset, kind string, hashField *string) error {
+	hash, err := recordPrice(ctx, asset, kind)
+	if err != nil {
+		return err
+	}
+	mergeField(hashField, hash)
+
+	return nil
+}
+
+// putPrice writes a price record to its collection and returns its hash. The record must carry a salt of at
+// least minSaltLength bytes, which the hash covers along with the price.
+func putPrice(ctx contractapi.TransactionContextInterface, price *PriceRecord) (string, error) {
+	salt, err := hex.DecodeString(price.Salt)
+	if err != nil || len(salt) < minSaltLength {
+		return "", fmt.Errorf("the %s price of asset %s has no salt of at least %d bytes", price.Kind, price.ID, minSaltLength)
+	}
+
+	collection := priceCollections[price.Kind]
+	key, err := priceKey(ctx, price.ID, price.Kind)
+	if err != nil {
+		return "", err
+	}
+
+	priceJSON, err := json.Marshal(price)
+	if err != nil {
+		return "", err
+	}
+	err = ctx.GetStub().PutPrivateData(collection, key, priceJSON)
+	if err != nil {
+		return "", fmt.Errorf("failed to put %s price of asset %s in %s: %v", price.Kind, price.ID, collection, err)
+	}
+
+	hash := sha256.Sum256(priceJSON)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// readPrice returns a price record from a collection, or nil if none has been stored
+func readPrice(ctx contractapi.TransactionContextInterface, collection, id, kind string) (*PriceRecord, error) {
+	key, err := priceKey(ctx, id, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	priceJSON, err := ctx.GetStub().GetPrivateData(collection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s price of asset %s from %s: %v", kind, id, collection, err)
+	}
+	if priceJSON == nil {
+		return nil, nil
+	}
+
+	var price PriceRecord
+	err = json.Unmarshal(priceJSON, &price)
+	if err != nil {
+		return nil, err
+	}
+
+	return &price, nil
+}
+
+func priceKey(ctx contractapi.TransactionContextInterface, id, kind string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(priceObjectType, []string{id, kind})
+}
+
+// isCollectionMember reports whether an organization belongs to a collection
+func isCollectionMember(collection, mspID string) bool {
+	for _, member := range collectionMembers[collection] {
+		if member == mspID {
+			return true
+		}
+	}
+
+	return false
+}
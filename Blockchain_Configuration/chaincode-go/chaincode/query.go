@@ -0,0 +1,145 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// QueryAssets runs a CouchDB rich query over the assets in the world state.
+// The argument is either a full query such as {"selector": {...}, "sort": [...]}
+// or just the selector, for example {"Variety": "Roma", "HarvestDate": {"$gte": "2024-01-01"}}.
+func (s *SmartContract) QueryAssets(ctx contractapi.TransactionContextInterface, queryJSON string) ([]*Asset, error) {
+	queryString, err := buildAssetQuery(queryJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run query: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var assets []*Asset
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		asset, err := unmarshalAsset(queryResponse.Value)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+
+	return assets, nil
+}
+
+// buildAssetQuery turns a caller supplied query or selector into a CouchDB query restricted to asset documents
+func buildAssetQuery(queryJSON string) (string, error) {
+	var query map[string]interface{}
+	err := json.Unmarshal([]byte(queryJSON), &query)
+	if err != nil {
+		return "", fmt.Errorf("invalid query: %v", err)
+	}
+
+	if _, ok := query["selector"]; !ok {
+		query = map[string]interface{}{"selector": query}
+	}
+	selector, ok := query["selector"].(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("invalid query: selector must be a JSON object")
+	}
+	selector["DocType"] = assetDocType
+
+	queryBytes, err := json.Marshal(query)
+	if err != nil {
+		return "", err
+	}
+
+	return string(queryBytes), nil
+}
+
+// PaginatedQueryResult is one page of assets together with the bookmark to fetch the next page
+type PaginatedQueryResult struct {
+	Records             []*Asset `json:"Records"`
+	FetchedRecordsCount int32    `json:"FetchedRecordsCount"`
+	Bookmark            string   `json:"Bookmark"`
+}
+
+// GetAssetsWithPagination returns a page of at most pageSize assets, starting at the bookmark
+// returned with the previous page. An empty bookmark starts from the first asset.
+func (s *SmartContract) GetAssetsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*PaginatedQueryResult, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("page size must be positive, got %d", pageSize)
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByRangeWithPagination("", "", pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	assets := []*Asset{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		asset, err := unmarshalAsset(queryResponse.Value)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+
+	return &PaginatedQueryResult{
+		Records:             assets,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
+}
+
+// QueryAssetsWithPagination runs a CouchDB rich query like QueryAssets, returning a page of
+// at most pageSize assets starting at the bookmark returned with the previous page
+func (s *SmartContract) QueryAssetsWithPagination(ctx contractapi.TransactionContextInterface, queryJSON string, pageSize int32, bookmark string) (*PaginatedQueryResult, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("page size must be positive, got %d", pageSize)
+	}
+
+	queryString, err := buildAssetQuery(queryJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run query: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	assets := []*Asset{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		asset, err := unmarshalAsset(queryResponse.Value)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+
+	return &PaginatedQueryResult{
+		Records:             assets,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
+}
@@ -0,0 +1,184 @@
+package chaincode
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RecallImpact lists the lots affected by a recall and the retailers that received them
+type RecallImpact struct {
+	AssetID     string            `json:"AssetID"`
+	Reason      string            `json:"Reason"`
+	AffectedIDs []string          `json:"AffectedIDs"`
+	Retailers   []*RetailerImpact `json:"Retailers"`
+}
+
+// RetailerImpact is the product from a recalled lot that reached one retailer. Quantity adds up what the
+// retailer received of each lot not used up by a split or merge, so nothing is counted twice.
+type RetailerImpact struct {
+	RetailerId   string   `json:"RetailerId"`
+	RetailerName string   `json:"RetailerName"`
+	LotIDs       []string `json:"LotIDs"`
+	Quantity     float64  `json:"Quantity"`
+	Unit         string   `json:"Unit"`
+}
+
+// RecallAsset recalls a lot and every lot split, merged or transferred off from it, so none of them can be
+// traded any further. Only the farmer organization, where lots originate, may recall them. Lots used up by a
+// split or merge keep the Consumed status but record the reason, and lots that have already been destroyed
+// are left as they are. A single AssetRecalled event names the lot and lists the derived lots in RelatedIDs.
+func (s *SmartContract) RecallAsset(ctx contractapi.TransactionContextInterface, id, reason string) error {
+	err := requireMSP(ctx, "recall asset "+id, FarmerMSP)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		return fmt.Errorf("a recall of asset %s needs a reason", id)
+	}
+
+	root, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+	if root.RecallReason != "" {
+		return fmt.Errorf("the asset %s has already been recalled", root.ID)
+	}
+	if root.Status != StatusConsumed {
+		err = checkTransition(root.ID, root.Status, StatusRecalled)
+		if err != nil {
+			return err
+		}
+	}
+
+	affected, err := s.derivedAssets(ctx, root)
+	if err != nil {
+		return err
+	}
+
+	var derivedIDs []string
+	var derived []*Asset
+	for _, asset := range affected[1:] {
+		if asset.RecallReason != "" || asset.Status == StatusDestroyed {
+			continue
+		}
+		derivedIDs = append(derivedIDs, asset.ID)
+		derived = append(derived, asset)
+	}
+
+	markRecalled(root, reason)
+	err = emitAssetEvent(ctx, EventAssetRecalled, root, derivedIDs...)
+	if err != nil {
+		return err
+	}
+	err = putAsset(ctx, root)
+	if err != nil {
+		return err
+	}
+
+	for _, asset := range derived {
+		markRecalled(asset, fmt.Sprintf("%s (recall of %s)", reason, root.ID))
+		err = putAsset(ctx, asset)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetRecallImpact lists the lots derived from an asset and, for each retailer that received any of them,
+// the lots it holds and their quantity. It can be run before a recall to size it or afterwards to notify
+// the retailers.
+func (s *SmartContract) GetRecallImpact(ctx contractapi.TransactionContextInterface, id string) (*RecallImpact, error) {
+	root, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := s.derivedAssets(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+
+	impact := &RecallImpact{
+		AssetID:   root.ID,
+		Reason:    root.RecallReason,
+		Retailers: []*RetailerImpact{},
+	}
+	byID := make(map[string]*Asset, len(affected))
+	for _, asset := range affected {
+		byID[asset.ID] = asset
+	}
+	retailers := make(map[string]*RetailerImpact)
+	for _, asset := range affected {
+		impact.AffectedIDs = append(impact.AffectedIDs, asset.ID)
+		if asset.RetailerId == "" || asset.Status == StatusConsumed {
+			continue
+		}
+
+		retailer, ok := retailers[asset.RetailerId]
+		if !ok {
+			retailer = &RetailerImpact{
+				RetailerId:   asset.RetailerId,
+				RetailerName: asset.RetailerName,
+				Unit:         asset.Unit,
+			}
+			retailers[asset.RetailerId] = retailer
+			impact.Retailers = append(impact.Retailers, retailer)
+		}
+		retailer.LotIDs = append(retailer.LotIDs, asset.ID)
+		retailer.Quantity += receivedQuantity(asset, byID)
+	}
+	sort.Slice(impact.Retailers, func(i, j int) bool {
+		return impact.Retailers[i].RetailerId < impact.Retailers[j].RetailerId
+	})
+
+	return impact, nil
+}
+
+// receivedQuantity returns how much of a lot its holder received: its quantity less the sub-lots transferred
+// off it before the handover. Every such sub-lot is derived from the lot, so it is in assets.
+func receivedQuantity(asset *Asset, assets map[string]*Asset) float64 {
+	quantity := asset.Quantity
+	for _, childID := range asset.ChildIDs {
+		if child, ok := assets[childID]; ok && child.ParentID == asset.ID {
+			quantity -= child.Quantity
+		}
+	}
+
+	return quantity
+}
+
+// markRecalled records a recall on a lot. A lot used up by a split or merge stays Consumed, since what it held
+// is recalled through the lots it went into.
+func markRecalled(asset *Asset, reason string) {
+	if asset.Status != StatusConsumed {
+		asset.Status = StatusRecalled
+	}
+	asset.RecallReason = reason
+}
+
+// derivedAssets returns an asset followed by every asset split, merged or transferred off from it, directly or through other
+// lots, each listed once
+func (s *SmartContract) derivedAssets(ctx contractapi.TransactionContextInterface, root *Asset) ([]*Asset, error) {
+	assets := []*Asset{root}
+	visited := map[string]bool{root.ID: true}
+	for i := 0; i < len(assets); i++ {
+		for _, childID := range assets[i].ChildIDs {
+			if visited[childID] {
+				continue
+			}
+			visited[childID] = true
+
+			child, err := s.ReadAsset(ctx, childID)
+			if err != nil {
+				return nil, err
+			}
+			assets = append(assets, child)
+		}
+	}
+
+	return assets, nil
+}
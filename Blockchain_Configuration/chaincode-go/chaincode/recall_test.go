@@ -0,0 +1,170 @@
+package chaincode
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestRecallAsset(t *testing.T) {
+	tests := []struct {
+		name         string
+		client       testIdentity
+		id           string
+		reason       string
+		wantErr      string
+		wantRecalled []string
+	}{
+		{name: "harvest lot", client: farmerClient, id: "A", reason: "Salmonella", wantRecalled: []string{"A", "A1", "A2", "M"}},
+		{name: "sub-lot", client: farmerClient, id: "A1", reason: "Salmonella", wantRecalled: []string{"A1", "M"}},
+		{name: "lot merged from several farms", client: farmerClient, id: "M", reason: "Salmonella", wantRecalled: []string{"M"}},
+		{name: "organization other than a farmer", client: wholesalerClient, id: "M", reason: "Salmonella", wantErr: "not authorized to recall asset M"},
+		{name: "no reason", client: farmerClient, id: "A", wantErr: "needs a reason"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newTestLedger(t)
+			ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+				return ledger.contract.RegisterParticipant(ctx, `{"id": "farmer-2", "role": "Farmer", "name": "Second farm", "location": "Wenchi"}`)
+			})
+			ledger.harvest("A", 100)
+			ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+				return ledger.contract.CreateAsset(ctx, `{"id": "B", "farmerId": "farmer-2", "variety": "Roma", "harvestDate": "2024-03-01T06:00:00Z", "quantity": 50, "unit": "kg"}`)
+			})
+			ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+				return ledger.contract.SplitAsset(ctx, `{"id": "A", "children": [{"id": "A1", "quantity": 60}, {"id": "A2", "quantity": 40}]}`)
+			})
+			ledger.transfer(farmerClient, wholesalerClient, "A1", testWholesaler)
+			ledger.transfer(farmerClient, wholesalerClient, "B", testWholesaler)
+			ledger.mustSubmit(wholesalerClient, func(ctx contractapi.TransactionContextInterface) error {
+				return ledger.contract.MergeAssets(ctx, `{"id": "M", "sourceIds": ["A1", "B"]}`)
+			})
+
+			err := ledger.submit(tt.client, nil, func(ctx contractapi.TransactionContextInterface) error {
+				return ledger.contract.RecallAsset(ctx, tt.id, tt.reason)
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				if asset := ledger.asset(tt.id); asset.RecallReason != "" {
+					t.Errorf("a rejected recall marked %s recalled: %q", tt.id, asset.RecallReason)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			recalled := make(map[string]bool)
+			for _, id := range tt.wantRecalled {
+				recalled[id] = true
+			}
+			for _, id := range []string{"A", "A1", "A2", "B", "M"} {
+				asset := ledger.asset(id)
+				if recalled[id] != (asset.RecallReason != "") {
+					t.Errorf("got %s %s with recall reason %q", id, asset.Status, asset.RecallReason)
+				}
+				if recalled[id] && asset.Status != StatusConsumed && asset.Status != StatusRecalled {
+					t.Errorf("got recalled lot %s %s", id, asset.Status)
+				}
+			}
+			if ledger.lastEvent() != EventAssetRecalled {
+				t.Errorf("got event %q, want %q", ledger.lastEvent(), EventAssetRecalled)
+			}
+
+			err = ledger.submit(tt.client, nil, func(ctx contractapi.TransactionContextInterface) error {
+				return ledger.contract.RecallAsset(ctx, tt.id, tt.reason)
+			})
+			if err == nil || !strings.Contains(err.Error(), "has already been recalled") {
+				t.Errorf("got error %v recalling %s twice", err, tt.id)
+			}
+		})
+	}
+}
+
+func TestGetRecallImpact(t *testing.T) {
+	tests := []struct {
+		name  string
+		moves func(*testLedger)
+		want  map[string]float64
+	}{
+		{
+			name: "whole lot to one retailer",
+			moves: func(ledger *testLedger) {
+				ledger.transfer(farmerClient, wholesalerClient, "A", testWholesaler)
+				ledger.transfer(wholesalerClient, retailerClient, "A", testRetailer)
+			},
+			want: map[string]float64{testRetailer: 100},
+		},
+		{
+			name: "part to one retailer and the rest to another",
+			moves: func(ledger *testLedger) {
+				ledger.transfer(farmerClient, wholesalerClient, "A", testWholesaler)
+				ledger.transferPart(wholesalerClient, retailerClient, "A", testRetailer, "A-1", 30)
+				ledger.transfer(wholesalerClient, retailerClient, "A", "retailer-2")
+			},
+			want: map[string]float64{testRetailer: 30, "retailer-2": 70},
+		},
+		{
+			name: "parts split off at the farm and at the wholesaler",
+			moves: func(ledger *testLedger) {
+				ledger.transferPart(farmerClient, wholesalerClient, "A", testWholesaler, "A-1", 40)
+				ledger.transfer(farmerClient, wholesalerClient, "A", testWholesaler)
+				ledger.transfer(wholesalerClient, retailerClient, "A-1", testRetailer)
+				ledger.transferPart(wholesalerClient, retailerClient, "A", "retailer-2", "A-2", 25)
+				ledger.transfer(wholesalerClient, retailerClient, "A", testRetailer)
+			},
+			want: map[string]float64{testRetailer: 75, "retailer-2": 25},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newTestLedger(t)
+			ledger.mustSubmit(retailerClient, func(ctx contractapi.TransactionContextInterface) error {
+				return ledger.contract.RegisterParticipant(ctx, `{"id": "retailer-2", "role": "Retailer", "name": "Second shop"}`)
+			})
+			ledger.harvest("A", 100)
+			tt.moves(ledger)
+
+			var impact *RecallImpact
+			ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+				var err error
+				impact, err = ledger.contract.GetRecallImpact(ctx, "A")
+				return err
+			})
+
+			got := make(map[string]float64)
+			var total float64
+			for _, retailer := range impact.Retailers {
+				got[retailer.RetailerId] = retailer.Quantity
+				total += retailer.Quantity
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got retailer quantities %v, want %v", got, tt.want)
+			}
+			if total != 100 {
+				t.Errorf("got %g kg reaching retailers from a 100 kg harvest", total)
+			}
+		})
+	}
+}
+
+// transferPart offers part of a lot to buyerID as the sub-lot lotID and has buyer accept it
+func (ledger *testLedger) transferPart(seller, buyer testIdentity, assetID, buyerID, lotID string, quantity float64) {
+	ledger.t.Helper()
+	var offerID string
+	ledger.mustSubmit(seller, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		offerID, err = ledger.contract.OfferTransfer(ctx, fmt.Sprintf(`{"assetId": %q, "buyerId": %q, "quantity": %g, "lotId": %q}`, assetID, buyerID, quantity, lotID))
+		return err
+	})
+	ledger.mustSubmit(buyer, func(ctx contractapi.TransactionContextInterface) error {
+		return ledger.contract.AcceptTransfer(ctx, offerID)
+	})
+}
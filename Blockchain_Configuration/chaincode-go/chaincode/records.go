@@ -0,0 +1,153 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// HarvestRecord is the payload a farmer submits to create a lot or update its harvest details.
+// Empty fields leave the stored value unchanged. The farmer must be registered, and the farmer's name is
+// taken from the participant registry rather than from farmerName.
+type HarvestRecord struct {
+	ID           string   `json:"id"`
+	FarmerId     string   `json:"farmerId"`
+	FarmerName   string   `json:"farmerName"`
+	FarmLocation string   `json:"farmLocation"`
+	Variety      string   `json:"variety"`
+	BatchNo      string   `json:"batchNo"`
+	HarvestDate  string   `json:"harvestDate"`
+	Quantity     *float64 `json:"quantity"`
+	Unit         string   `json:"unit"`
+}
+
+// WholesalePurchase is the payload a wholesaler submits to correct the purchase details of a lot it holds.
+// The wholesaler must be registered, and its registered name replaces wholesalerName.
+type WholesalePurchase struct {
+	ID                string `json:"id"`
+	WholesalerId      string `json:"wholesalerId"`
+	WholesalerName    string `json:"wholesalerName"`
+	WholesalerBuyDate string `json:"wholesalerBuyDate"`
+}
+
+// RetailPurchase is the payload a retailer submits to correct the purchase details of a lot it holds.
+// The retailer must be registered, and its registered name replaces retailerName.
+type RetailPurchase struct {
+	ID              string `json:"id"`
+	RetailerId      string `json:"retailerId"`
+	RetailerName    string `json:"retailerName"`
+	RetailerBuyDate string `json:"retailerBuyDate"`
+}
+
+// decodePayload strictly decodes a JSON transaction payload, rejecting unknown fields
+func decodePayload(payloadJSON string, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(payloadJSON)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid payload: %v", err)
+	}
+
+	return nil
+}
+
+// validate checks that a harvest record holds everything needed to create a lot
+func (h *HarvestRecord) validate() error {
+	required := []struct {
+		name    string
+		present bool
+	}{
+		{"id", h.ID != ""},
+		{"farmerId", h.FarmerId != ""},
+		{"variety", h.Variety != ""},
+		{"harvestDate", h.HarvestDate != ""},
+		{"quantity", h.Quantity != nil},
+		{"unit", h.Unit != ""},
+	}
+	for _, field := range required {
+		if !field.present {
+			return fmt.Errorf("invalid payload: %s is required", field.name)
+		}
+	}
+
+	return nil
+}
+
+// normalize checks the typed fields of a harvest record and brings dates and units to their stored form
+func (h *HarvestRecord) normalize() error {
+	var err error
+	if h.HarvestDate != "" {
+		h.HarvestDate, err = normalizeDate(h.HarvestDate)
+		if err != nil {
+			return fmt.Errorf("invalid payload: harvestDate: %v", err)
+		}
+	}
+	if h.Quantity != nil && *h.Quantity <= 0 {
+		return fmt.Errorf("invalid payload: quantity must be greater than zero")
+	}
+	if h.Unit != "" {
+		h.Unit, err = normalizeUnit(h.Unit)
+		if err != nil {
+			return fmt.Errorf("invalid payload: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// normalize checks the purchase date and brings it to its stored form
+func (p *WholesalePurchase) normalize() error {
+	var err error
+	if p.WholesalerBuyDate != "" {
+		p.WholesalerBuyDate, err = normalizeDate(p.WholesalerBuyDate)
+		if err != nil {
+			return fmt.Errorf("invalid payload: wholesalerBuyDate: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// normalize checks the purchase date and brings it to its stored form
+func (p *RetailPurchase) normalize() error {
+	var err error
+	if p.RetailerBuyDate != "" {
+		p.RetailerBuyDate, err = normalizeDate(p.RetailerBuyDate)
+		if err != nil {
+			return fmt.Errorf("invalid payload: retailerBuyDate: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (h *HarvestRecord) mergeInto(asset *Asset) {
+	mergeField(&asset.FarmerId, h.FarmerId)
+	mergeField(&asset.FarmerName, h.FarmerName)
+	mergeField(&asset.FarmLocation, h.FarmLocation)
+	mergeField(&asset.Variety, h.Variety)
+	mergeField(&asset.BatchNo, h.BatchNo)
+	mergeField(&asset.HarvestDate, h.HarvestDate)
+	if h.Quantity != nil {
+		asset.Quantity = *h.Quantity
+	}
+	mergeField(&asset.Unit, h.Unit)
+}
+
+func (p *WholesalePurchase) mergeInto(asset *Asset) {
+	mergeField(&asset.WholesalerId, p.WholesalerId)
+	mergeField(&asset.WholesalerName, p.WholesalerName)
+	mergeField(&asset.WholesalerBuyDate, p.WholesalerBuyDate)
+}
+
+func (p *RetailPurchase) mergeInto(asset *Asset) {
+	mergeField(&asset.RetailerId, p.RetailerId)
+	mergeField(&asset.RetailerName, p.RetailerName)
+	mergeField(&asset.RetailerBuyDate, p.RetailerBuyDate)
+}
+
+// mergeField overwrites a stored value only when the payload supplies a new one
+func mergeField(stored *string, value string) {
+	if value != "" {
+		*stored = value
+	}
+}
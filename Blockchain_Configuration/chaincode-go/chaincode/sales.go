@@ -0,0 +1,245 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// saleObjectType is the composite key object type of retail sales, keyed by asset and sale ID
+const saleObjectType = "sale"
+
+// Sale is a quantity of a lot sold to consumers, in the lot's unit
+type Sale struct {
+	ID         string  `json:"ID"`
+	AssetID    string  `json:"AssetID"`
+	Quantity   float64 `json:"Quantity"`
+	Unit       string  `json:"Unit"`
+	SaleDate   string  `json:"SaleDate"`
+	RetailerId string  `json:"RetailerId"`
+	Remaining  float64 `json:"Remaining"`
+}
+
+// SaleLine is one line of a point-of-sale batch
+type SaleLine struct {
+	AssetID  string  `json:"assetId"`
+	Quantity float64 `json:"quantity"`
+	SaleDate string  `json:"saleDate"`
+}
+
+// SalesReport summarizes a RecordSales batch
+type SalesReport struct {
+	Recorded int            `json:"Recorded"`
+	SoldOut  []string       `json:"SoldOut"`
+	Failed   []*SaleFailure `json:"Failed"`
+}
+
+// SaleFailure records a batch line RecordSales could not apply, and why. Line counts from 1.
+type SaleFailure struct {
+	Line    int    `json:"Line"`
+	AssetID string `json:"AssetID"`
+	Reason  string `json:"Reason"`
+}
+
+// RecordSale records a sale of part of a lot held by a retailer, in the lot's unit. The quantity is taken off
+// what remains and the lot becomes Sold when nothing is left. A sale of more than remains is rejected.
+func (s *SmartContract) RecordSale(ctx contractapi.TransactionContextInterface, assetID string, quantity float64, saleDate string) error {
+	asset, err := s.ReadAsset(ctx, assetID)
+	if err != nil {
+		return err
+	}
+	sale, err := applySale(ctx, asset, &SaleLine{AssetID: assetID, Quantity: quantity, SaleDate: saleDate}, ctx.GetStub().GetTxID())
+	if err != nil {
+		return err
+	}
+
+	eventType := EventSaleRecorded
+	if asset.Status == StatusSold {
+		eventType = EventAssetSold
+	}
+	err = emitAssetEvent(ctx, eventType, asset)
+	if err != nil {
+		return err
+	}
+	err = putSale(ctx, sale)
+	if err != nil {
+		return err
+	}
+
+	return putAsset(ctx, asset)
+}
+
+// RecordSales records an end-of-day batch of sales from a JSON array of SaleLine. Lines for the same lot are
+// applied in order. A line that cannot be applied, such as one selling more than remains, is skipped and
+// listed in the report, and the rest of the batch is still recorded.
+func (s *SmartContract) RecordSales(ctx contractapi.TransactionContextInterface, salesJSON string) (*SalesReport, error) {
+	var lines []*SaleLine
+	err := decodePayload(salesJSON, &lines)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("invalid payload: the batch has no sales")
+	}
+	err = requireMSP(ctx, "record sales", RetailerMSP)
+	if err != nil {
+		return nil, err
+	}
+
+	// Writes are only visible after the transaction commits, so every lot is read once and kept here
+	assets := make(map[string]*Asset)
+	var assetIDs []string
+	sold := make(map[string]bool)
+	report := &SalesReport{SoldOut: []string{}, Failed: []*SaleFailure{}}
+	for i, line := range lines {
+		asset, ok := assets[line.AssetID]
+		if !ok {
+			asset, err = s.ReadAsset(ctx, line.AssetID)
+			if err != nil {
+				report.Failed = append(report.Failed, &SaleFailure{Line: i + 1, AssetID: line.AssetID, Reason: err.Error()})
+				continue
+			}
+			assets[asset.ID] = asset
+			assetIDs = append(assetIDs, asset.ID)
+		}
+
+		sale, err := applySale(ctx, asset, line, fmt.Sprintf("%s-%d", ctx.GetStub().GetTxID(), i+1))
+		if err != nil {
+			report.Failed = append(report.Failed, &SaleFailure{Line: i + 1, AssetID: line.AssetID, Reason: err.Error()})
+			continue
+		}
+		err = putSale(ctx, sale)
+		if err != nil {
+			return nil, err
+		}
+		report.Recorded++
+		sold[asset.ID] = true
+		if asset.Status == StatusSold {
+			report.SoldOut = append(report.SoldOut, asset.ID)
+		}
+	}
+
+	var soldIDs []string
+	for _, id := range assetIDs {
+		if !sold[id] {
+			continue
+		}
+		err = putAsset(ctx, assets[id])
+		if err != nil {
+			return nil, err
+		}
+		soldIDs = append(soldIDs, id)
+	}
+
+	if report.Recorded > 0 {
+		err = setEvent(ctx, &AssetEvent{
+			Type:       EventSalesRecorded,
+			RelatedIDs: soldIDs,
+			ChangedFields: map[string]interface{}{
+				"Recorded": report.Recorded,
+				"SoldOut":  report.SoldOut,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// GetSales returns the sales recorded against a lot, oldest first
+func (s *SmartContract) GetSales(ctx contractapi.TransactionContextInterface, assetID string) ([]*Sale, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(saleObjectType, []string{assetID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	sales := []*Sale{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var sale Sale
+		err = json.Unmarshal(queryResponse.Value, &sale)
+		if err != nil {
+			return nil, err
+		}
+		sales = append(sales, &sale)
+	}
+	sort.SliceStable(sales, func(i, j int) bool {
+		return sales[i].SaleDate < sales[j].SaleDate
+	})
+
+	return sales, nil
+}
+
+// applySale checks a sale against a lot and takes it off the remaining quantity, marking the lot Sold when
+// nothing is left. Only the retailer organization holding the lot may sell from it.
+func applySale(ctx contractapi.TransactionContextInterface, asset *Asset, line *SaleLine, saleID string) (*Sale, error) {
+	if asset.Status != StatusWithRetailer {
+		return nil, fmt.Errorf("the asset %s is %s and cannot be sold", asset.ID, asset.Status)
+	}
+	err := requireMSP(ctx, "record sales of asset "+asset.ID, asset.CurrentOwnerMSP)
+	if err != nil {
+		return nil, err
+	}
+	if line.Quantity <= 0 {
+		return nil, fmt.Errorf("invalid payload: quantity must be greater than zero")
+	}
+	saleDate, err := normalizeDate(line.SaleDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload: saleDate: %v", err)
+	}
+	if saleDate < asset.RetailerBuyDate {
+		return nil, fmt.Errorf("the sale date %s is before the retailer bought asset %s", saleDate, asset.ID)
+	}
+	if line.Quantity > asset.RemainingQuantity+quantityTolerance {
+		return nil, fmt.Errorf("the asset %s has %g %s remaining, less than the %g %s sold",
+			asset.ID, asset.RemainingQuantity, asset.Unit, line.Quantity, asset.Unit)
+	}
+
+	sale := &Sale{
+		ID:         saleID,
+		AssetID:    asset.ID,
+		Quantity:   line.Quantity,
+		Unit:       asset.Unit,
+		SaleDate:   saleDate,
+		RetailerId: asset.RetailerId,
+	}
+	asset.RemainingQuantity -= line.Quantity
+	if math.Abs(asset.RemainingQuantity) <= quantityTolerance {
+		err = checkTransition(asset.ID, asset.Status, StatusSold)
+		if err != nil {
+			return nil, err
+		}
+		asset.Status = StatusSold
+		releaseCustody(asset)
+	}
+	sale.Remaining = asset.RemainingQuantity
+
+	return sale, nil
+}
+
+func putSale(ctx contractapi.TransactionContextInterface, sale *Sale) error {
+	key, err := ctx.GetStub().CreateCompositeKey(saleObjectType, []string{sale.AssetID, sale.ID})
+	if err != nil {
+		return err
+	}
+	saleJSON, err := json.Marshal(sale)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(key, saleJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put sale %s of asset %s: %v", sale.ID, sale.AssetID, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package chaincode
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// stockRetailer moves a lot from the test farmer through the wholesaler to the test retailer
+func (ledger *testLedger) stockRetailer(id string, quantity float64) {
+	ledger.t.Helper()
+	ledger.harvest(id, quantity)
+	ledger.transfer(farmerClient, wholesalerClient, id, testWholesaler)
+	ledger.transfer(wholesalerClient, retailerClient, id, testRetailer)
+}
+
+func TestRecordSale(t *testing.T) {
+	saleDate := time.Now().UTC().Add(time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name          string
+		setup         func(*testLedger)
+		client        testIdentity
+		quantity      float64
+		saleDate      string
+		wantErr       string
+		wantRemaining float64
+		wantStatus    string
+		wantEvent     string
+	}{
+		{name: "part of the lot", client: retailerClient, quantity: 30, saleDate: saleDate, wantRemaining: 70, wantStatus: StatusWithRetailer, wantEvent: EventSaleRecorded},
+		{name: "the rest of the lot", client: retailerClient, quantity: 100, saleDate: saleDate, wantRemaining: 0, wantStatus: StatusSold, wantEvent: EventAssetSold},
+		{name: "more than remains", client: retailerClient, quantity: 120, saleDate: saleDate, wantErr: "less than the 120 kg sold", wantRemaining: 100, wantStatus: StatusWithRetailer},
+		{name: "no quantity", client: retailerClient, quantity: 0, saleDate: saleDate, wantErr: "quantity must be greater than zero", wantRemaining: 100, wantStatus: StatusWithRetailer},
+		{name: "before the retailer bought it", client: retailerClient, quantity: 10, saleDate: "2024-03-02T00:00:00Z", wantErr: "before the retailer bought", wantRemaining: 100, wantStatus: StatusWithRetailer},
+		{name: "unreadable date", client: retailerClient, quantity: 10, saleDate: "yesterday", wantErr: "invalid payload: saleDate", wantRemaining: 100, wantStatus: StatusWithRetailer},
+		{name: "organization not holding the lot", client: wholesalerClient, quantity: 10, saleDate: saleDate, wantErr: "not authorized to record sales of asset A", wantRemaining: 100, wantStatus: StatusWithRetailer},
+		{
+			name: "lot not yet with a retailer",
+			setup: func(ledger *testLedger) {
+				ledger.harvest("B", 100)
+			},
+			client:        farmerClient,
+			quantity:      10,
+			saleDate:      saleDate,
+			wantErr:       "is Harvested and cannot be sold",
+			wantRemaining: 100,
+			wantStatus:    StatusWithRetailer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newTestLedger(t)
+			ledger.stockRetailer("A", 100)
+			assetID := "A"
+			if tt.setup != nil {
+				tt.setup(ledger)
+				assetID = "B"
+			}
+
+			err := ledger.submit(tt.client, nil, func(ctx contractapi.TransactionContextInterface) error {
+				return ledger.contract.RecordSale(ctx, assetID, tt.quantity, tt.saleDate)
+			})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+
+			asset := ledger.asset("A")
+			if asset.RemainingQuantity != tt.wantRemaining || asset.Status != tt.wantStatus {
+				t.Errorf("got %s with %g remaining, want %s with %g", asset.Status, asset.RemainingQuantity, tt.wantStatus, tt.wantRemaining)
+			}
+			if tt.wantStatus == StatusSold && asset.CurrentOwnerId != "" {
+				t.Errorf("a sold-out lot is still held by %q", asset.CurrentOwnerId)
+			}
+			if tt.wantErr != "" {
+				return
+			}
+			if ledger.lastEvent() != tt.wantEvent {
+				t.Errorf("got event %q, want %q", ledger.lastEvent(), tt.wantEvent)
+			}
+			sales := ledger.sales("A")
+			if len(sales) != 1 || sales[0].Quantity != tt.quantity || sales[0].RetailerId != testRetailer || sales[0].Remaining != tt.wantRemaining {
+				t.Errorf("got sales %+v", sales)
+			}
+		})
+	}
+}
+
+func TestRecordSales(t *testing.T) {
+	saleDate := time.Now().UTC().Add(time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name          string
+		client        testIdentity
+		lines         string
+		wantErr       string
+		wantRecorded  int
+		wantSoldOut   []string
+		wantFailed    []int
+		wantRemaining map[string]float64
+	}{
+		{
+			name:          "every line applies",
+			client:        retailerClient,
+			lines:         `[{"assetId": "A", "quantity": 40, "saleDate": %[1]q}, {"assetId": "B", "quantity": 10, "saleDate": %[1]q}, {"assetId": "A", "quantity": 60, "saleDate": %[1]q}]`,
+			wantRecorded:  3,
+			wantSoldOut:   []string{"A"},
+			wantRemaining: map[string]float64{"A": 0, "B": 40},
+		},
+		{
+			name:          "later lines see earlier ones",
+			client:        retailerClient,
+			lines:         `[{"assetId": "B", "quantity": 30, "saleDate": %[1]q}, {"assetId": "B", "quantity": 30, "saleDate": %[1]q}]`,
+			wantRecorded:  1,
+			wantSoldOut:   []string{},
+			wantFailed:    []int{2},
+			wantRemaining: map[string]float64{"A": 100, "B": 20},
+		},
+		{
+			name:          "failed lines are skipped",
+			client:        retailerClient,
+			lines:         `[{"assetId": "missing", "quantity": 1, "saleDate": %[1]q}, {"assetId": "A", "quantity": 10, "saleDate": "soon"}, {"assetId": "B", "quantity": 50, "saleDate": %[1]q}]`,
+			wantRecorded:  1,
+			wantSoldOut:   []string{"B"},
+			wantFailed:    []int{1, 2},
+			wantRemaining: map[string]float64{"A": 100, "B": 0},
+		},
+		{
+			name:    "empty batch",
+			client:  retailerClient,
+			lines:   `[]`,
+			wantErr: "the batch has no sales",
+		},
+		{
+			name:    "organization other than a retailer",
+			client:  wholesalerClient,
+			lines:   `[{"assetId": "A", "quantity": 10, "saleDate": %[1]q}]`,
+			wantErr: "not authorized to record sales",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newTestLedger(t)
+			ledger.stockRetailer("A", 100)
+			ledger.stockRetailer("B", 50)
+
+			var report *SalesReport
+			err := ledger.submit(tt.client, nil, func(ctx contractapi.TransactionContextInterface) error {
+				var err error
+				report, err = ledger.contract.RecordSales(ctx, fmt.Sprintf(tt.lines, saleDate))
+				return err
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if report.Recorded != tt.wantRecorded || !reflect.DeepEqual(report.SoldOut, tt.wantSoldOut) {
+				t.Errorf("got %d recorded and %v sold out, want %d and %v", report.Recorded, report.SoldOut, tt.wantRecorded, tt.wantSoldOut)
+			}
+			var failed []int
+			for _, failure := range report.Failed {
+				failed = append(failed, failure.Line)
+			}
+			if !reflect.DeepEqual(failed, tt.wantFailed) {
+				t.Errorf("got failed lines %v, want %v", failed, tt.wantFailed)
+			}
+			for id, remaining := range tt.wantRemaining {
+				if asset := ledger.asset(id); asset.RemainingQuantity != remaining {
+					t.Errorf("got %g remaining of %s, want %g", asset.RemainingQuantity, id, remaining)
+				}
+			}
+			if ledger.lastEvent() != EventSalesRecorded {
+				t.Errorf("got event %q, want %q", ledger.lastEvent(), EventSalesRecorded)
+			}
+		})
+	}
+}
+
+// sales reads the sales of a lot, failing the test if it cannot
+func (ledger *testLedger) sales(assetID string) []*Sale {
+	ledger.t.Helper()
+	var sales []*Sale
+	ledger.mustSubmit(retailerClient, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		sales, err = ledger.contract.GetSales(ctx, assetID)
+		return err
+	})
+	return sales
+}
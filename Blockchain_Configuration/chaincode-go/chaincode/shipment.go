@@ -0,0 +1,458 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key object types of shipments and the index that finds them by the assets they carry
+const (
+	shipmentObjectType = "shipment"
+	shipmentAssetIndex = "asset~shipment"
+)
+
+// States of a shipment
+const (
+	ShipmentPlanned   = "Planned"
+	ShipmentInTransit = "InTransit"
+	ShipmentDelivered = "Delivered"
+	ShipmentCancelled = "Cancelled"
+)
+
+// Shipment is a transport leg carrying lots from their holder to a buyer at the next stage of the supply chain.
+// Custody of the lots passes to the receiver when it confirms delivery. Lots recalled or destroyed before
+// delivery stay where they are and are listed in DroppedIDs.
+type Shipment struct {
+	ID           string   `json:"ID"`
+	Carrier      string   `json:"Carrier"`
+	Vehicle      string   `json:"Vehicle"`
+	Origin       string   `json:"Origin"`
+	Destination  string   `json:"Destination"`
+	AssetIDs     []string `json:"AssetIDs"`
+	Stage        string   `json:"Stage"`
+	SenderId     string   `json:"SenderId"`
+	SenderMSP    string   `json:"SenderMSP"`
+	ReceiverId   string   `json:"ReceiverId"`
+	ReceiverMSP  string   `json:"ReceiverMSP"`
+	Status       string   `json:"Status"`
+	DispatchedAt string   `json:"DispatchedAt"`
+	ArrivedAt    string   `json:"ArrivedAt"`
+	DroppedIDs   []string `json:"DroppedIDs,omitempty" metadata:",optional"`
+	Reason       string   `json:"Reason,omitempty" metadata:",optional"`
+}
+
+// ShipmentRequest is the payload the holder of some lots submits to plan a shipment of them
+type ShipmentRequest struct {
+	ID          string   `json:"id"`
+	Carrier     string   `json:"carrier"`
+	Vehicle     string   `json:"vehicle"`
+	Origin      string   `json:"origin"`
+	Destination string   `json:"destination"`
+	ReceiverID  string   `json:"receiverId"`
+	AssetIDs    []string `json:"assetIds"`
+}
+
+// CreateShipment plans a shipment of lots held by the same party to a registered buyer at the next stage:
+// a farmer ships to a wholesaler and a wholesaler to a retailer. Only the organization holding the lots may
+// ship them, and a lot can be on one open shipment at a time and not while it has an open transfer offer.
+func (s *SmartContract) CreateShipment(ctx contractapi.TransactionContextInterface, shipmentJSON string) error {
+	var request ShipmentRequest
+	err := decodePayload(shipmentJSON, &request)
+	if err != nil {
+		return err
+	}
+	if request.ID == "" || request.Carrier == "" || request.Origin == "" || request.Destination == "" {
+		return fmt.Errorf("invalid payload: id, carrier, origin and destination are required")
+	}
+	if len(request.AssetIDs) == 0 {
+		return fmt.Errorf("invalid payload: the shipment %s carries no assets", request.ID)
+	}
+	existing, err := readShipment(ctx, request.ID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("the shipment %s already exists", request.ID)
+	}
+
+	var assets []*Asset
+	seen := make(map[string]bool)
+	for _, id := range request.AssetIDs {
+		if seen[id] {
+			return fmt.Errorf("invalid payload: asset %s is listed more than once", id)
+		}
+		seen[id] = true
+
+		asset, err := s.ReadAsset(ctx, id)
+		if err != nil {
+			return err
+		}
+		if _, ok := transferStages[asset.Status]; !ok {
+			return fmt.Errorf("the asset %s is %s and cannot be shipped", asset.ID, asset.Status)
+		}
+		if len(assets) > 0 && !sameHolder(assets[0], asset) {
+			return fmt.Errorf("the assets %s and %s are not held by the same party and cannot be shipped together", assets[0].ID, asset.ID)
+		}
+		err = checkNotCommitted(ctx, asset.ID)
+		if err != nil {
+			return err
+		}
+		assets = append(assets, asset)
+	}
+
+	stage := transferStages[assets[0].Status]
+	sender, err := activeParticipant(ctx, sellerRole(assets[0].Status), assets[0].CurrentOwnerId)
+	if err != nil {
+		return err
+	}
+	err = requireMSP(ctx, "ship assets held by "+sender.ID, sender.MSPID)
+	if err != nil {
+		return err
+	}
+	receiver, err := activeParticipant(ctx, stage.buyerRole, request.ReceiverID)
+	if err != nil {
+		return err
+	}
+
+	shipment := &Shipment{
+		ID:          request.ID,
+		Carrier:     request.Carrier,
+		Vehicle:     request.Vehicle,
+		Origin:      request.Origin,
+		Destination: request.Destination,
+		AssetIDs:    request.AssetIDs,
+		Stage:       assets[0].Status,
+		SenderId:    sender.ID,
+		SenderMSP:   sender.MSPID,
+		ReceiverId:  receiver.ID,
+		ReceiverMSP: receiver.MSPID,
+		Status:      ShipmentPlanned,
+	}
+	err = putShipment(ctx, shipment)
+	if err != nil {
+		return err
+	}
+	for _, id := range shipment.AssetIDs {
+		indexKey, err := ctx.GetStub().CreateCompositeKey(shipmentAssetIndex, []string{id, shipment.ID})
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().PutState(indexKey, indexValue)
+		if err != nil {
+			return fmt.Errorf("failed to put index entry for shipment %s: %v", shipment.ID, err)
+		}
+	}
+
+	return shipmentEvent(ctx, EventShipmentCreated, shipment)
+}
+
+// DispatchShipment records that a planned shipment has left, at the transaction time. Only the sender's
+// organization may dispatch it.
+func (s *SmartContract) DispatchShipment(ctx contractapi.TransactionContextInterface, id string) error {
+	shipment, err := s.GetShipment(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = requireMSP(ctx, "dispatch shipment "+shipment.ID, shipment.SenderMSP)
+	if err != nil {
+		return err
+	}
+	if shipment.Status != ShipmentPlanned {
+		return fmt.Errorf("the shipment %s is %s and cannot be dispatched", shipment.ID, shipment.Status)
+	}
+	assets, err := s.shippedAssets(ctx, shipment)
+	if err != nil {
+		return err
+	}
+	if len(assets) == 0 {
+		return fmt.Errorf("every asset on shipment %s has been recalled or destroyed; cancel it instead", shipment.ID)
+	}
+
+	shipment.Status = ShipmentInTransit
+	shipment.DispatchedAt, err = txTime(ctx)
+	if err != nil {
+		return err
+	}
+	err = putShipment(ctx, shipment)
+	if err != nil {
+		return err
+	}
+
+	return shipmentEvent(ctx, EventShipmentDispatched, shipment)
+}
+
+// ReceiveShipment confirms delivery of a shipment in transit, at the transaction time. Every lot it carries
+// moves into the receiver's custody, bought on the arrival date; a purchase price can be added afterwards
+// with RecordWholesalePurchase or RecordRetailPurchase. Lots recalled or destroyed on the way are dropped, and
+// a shipment left with no lots is cancelled rather than delivered. Only the receiver's organization may confirm.
+func (s *SmartContract) ReceiveShipment(ctx contractapi.TransactionContextInterface, id string) error {
+	shipment, err := s.GetShipment(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = requireMSP(ctx, "receive shipment "+shipment.ID, shipment.ReceiverMSP)
+	if err != nil {
+		return err
+	}
+	if shipment.Status != ShipmentInTransit {
+		return fmt.Errorf("the shipment %s is %s and cannot be received", shipment.ID, shipment.Status)
+	}
+	stage := transferStages[shipment.Stage]
+	receiver, err := activeParticipant(ctx, stage.buyerRole, shipment.ReceiverId)
+	if err != nil {
+		return err
+	}
+	assets, err := s.shippedAssets(ctx, shipment)
+	if err != nil {
+		return err
+	}
+	if len(assets) == 0 {
+		shipment.Status = ShipmentCancelled
+		shipment.Reason = "every asset was recalled or destroyed before delivery"
+		err = putShipment(ctx, shipment)
+		if err != nil {
+			return err
+		}
+		return shipmentEvent(ctx, EventShipmentCancelled, shipment)
+	}
+
+	shipment.Status = ShipmentDelivered
+	shipment.ArrivedAt, err = txTime(ctx)
+	if err != nil {
+		return err
+	}
+	for _, asset := range assets {
+		err = checkTransition(asset.ID, asset.Status, stage.status)
+		if err != nil {
+			return err
+		}
+		handOver(asset, stage, receiver, shipment.ArrivedAt)
+		err = putAsset(ctx, asset)
+		if err != nil {
+			return err
+		}
+	}
+	err = putShipment(ctx, shipment)
+	if err != nil {
+		return err
+	}
+
+	return shipmentEvent(ctx, EventShipmentReceived, shipment)
+}
+
+// CancelShipment calls off a planned or in-transit shipment, leaving its lots with the sender. Only the
+// sender's organization may cancel it.
+func (s *SmartContract) CancelShipment(ctx contractapi.TransactionContextInterface, id, reason string) error {
+	shipment, err := s.GetShipment(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = requireMSP(ctx, "cancel shipment "+shipment.ID, shipment.SenderMSP)
+	if err != nil {
+		return err
+	}
+	if !isOpenShipment(shipment) {
+		return fmt.Errorf("the shipment %s is %s and cannot be cancelled", shipment.ID, shipment.Status)
+	}
+
+	shipment.Status = ShipmentCancelled
+	shipment.Reason = reason
+	err = putShipment(ctx, shipment)
+	if err != nil {
+		return err
+	}
+
+	return shipmentEvent(ctx, EventShipmentCancelled, shipment)
+}
+
+// GetShipment returns a shipment
+func (s *SmartContract) GetShipment(ctx contractapi.TransactionContextInterface, id string) (*Shipment, error) {
+	shipment, err := readShipment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if shipment == nil {
+		return nil, fmt.Errorf("the shipment %s does not exist", id)
+	}
+
+	return shipment, nil
+}
+
+// GetAssetShipments returns the shipments that carried or are carrying an asset
+func (s *SmartContract) GetAssetShipments(ctx contractapi.TransactionContextInterface, assetID string) ([]*Shipment, error) {
+	return assetShipments(ctx, assetID)
+}
+
+// assetShipments returns the shipments listed for an asset in the shipment index
+func assetShipments(ctx contractapi.TransactionContextInterface, assetID string) ([]*Shipment, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(shipmentAssetIndex, []string{assetID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	shipments := []*Shipment{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(attributes) != 2 {
+			return nil, fmt.Errorf("malformed %s index key %q", shipmentAssetIndex, queryResponse.Key)
+		}
+
+		shipment, err := readShipment(ctx, attributes[1])
+		if err != nil {
+			return nil, err
+		}
+		if shipment != nil {
+			shipments = append(shipments, shipment)
+		}
+	}
+
+	return shipments, nil
+}
+
+// shippedAssets reads the lots still to be carried by a shipment. Lots recalled or destroyed since it was created
+// are recorded in DroppedIDs and left out. Any other change of hands is an error.
+func (s *SmartContract) shippedAssets(ctx contractapi.TransactionContextInterface, shipment *Shipment) ([]*Asset, error) {
+	var assets []*Asset
+	shipment.DroppedIDs = nil
+	for _, id := range shipment.AssetIDs {
+		asset, err := s.ReadAsset(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if asset.Status == StatusRecalled || asset.Status == StatusDestroyed {
+			shipment.DroppedIDs = append(shipment.DroppedIDs, asset.ID)
+			continue
+		}
+		if asset.Status != shipment.Stage || asset.CurrentOwnerId != shipment.SenderId {
+			return nil, fmt.Errorf("the asset %s on shipment %s has changed hands since the shipment was created", asset.ID, shipment.ID)
+		}
+		assets = append(assets, asset)
+	}
+
+	return assets, nil
+}
+
+// checkNotCommitted returns an error if an asset is already on an open shipment or has an open transfer offer,
+// so it cannot be handed over twice
+func checkNotCommitted(ctx contractapi.TransactionContextInterface, assetID string) error {
+	offer, err := openOffer(ctx, assetID)
+	if err != nil {
+		return err
+	}
+	if offer != nil {
+		return fmt.Errorf("the asset %s already has an open offer %s", assetID, offer.ID)
+	}
+
+	shipment, err := openShipment(ctx, assetID)
+	if err != nil {
+		return err
+	}
+	if shipment != nil {
+		return fmt.Errorf("the asset %s is already on shipment %s", assetID, shipment.ID)
+	}
+
+	return nil
+}
+
+// openShipment returns the planned or in-transit shipment carrying an asset, if there is one
+func openShipment(ctx contractapi.TransactionContextInterface, assetID string) (*Shipment, error) {
+	shipments, err := assetShipments(ctx, assetID)
+	if err != nil {
+		return nil, err
+	}
+	for _, shipment := range shipments {
+		if isOpenShipment(shipment) {
+			return shipment, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// isOpenShipment reports whether a shipment has yet to be delivered or cancelled
+func isOpenShipment(shipment *Shipment) bool {
+	return shipment.Status == ShipmentPlanned || shipment.Status == ShipmentInTransit
+}
+
+// txTime returns the transaction timestamp in the stored date format
+func txTime(ctx contractapi.TransactionContextInterface) (string, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return "", fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+
+	return timestamp.AsTime().UTC().Format(time.RFC3339), nil
+}
+
+func readShipment(ctx contractapi.TransactionContextInterface, id string) (*Shipment, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(shipmentObjectType, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	shipmentJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shipment %s from world state: %v", id, err)
+	}
+	if shipmentJSON == nil {
+		return nil, nil
+	}
+
+	var shipment Shipment
+	err = json.Unmarshal(shipmentJSON, &shipment)
+	if err != nil {
+		return nil, err
+	}
+
+	return &shipment, nil
+}
+
+func putShipment(ctx contractapi.TransactionContextInterface, shipment *Shipment) error {
+	key, err := ctx.GetStub().CreateCompositeKey(shipmentObjectType, []string{shipment.ID})
+	if err != nil {
+		return err
+	}
+	shipmentJSON, err := json.Marshal(shipment)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(key, shipmentJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put shipment %s: %v", shipment.ID, err)
+	}
+
+	return nil
+}
+
+// shipmentEvent sets a shipment event listing the lots it carries in RelatedIDs
+func shipmentEvent(ctx contractapi.TransactionContextInterface, eventType string, shipment *Shipment) error {
+	return setEvent(ctx, &AssetEvent{
+		Type:       eventType,
+		RelatedIDs: shipment.AssetIDs,
+		ChangedFields: map[string]interface{}{
+			"ShipmentID":   shipment.ID,
+			"Carrier":      shipment.Carrier,
+			"Vehicle":      shipment.Vehicle,
+			"Origin":       shipment.Origin,
+			"Destination":  shipment.Destination,
+			"SenderId":     shipment.SenderId,
+			"ReceiverId":   shipment.ReceiverId,
+			"Status":       shipment.Status,
+			"DispatchedAt": shipment.DispatchedAt,
+			"ArrivedAt":    shipment.ArrivedAt,
+			"DroppedIDs":   shipment.DroppedIDs,
+			"Reason":       shipment.Reason,
+		},
+	})
+}
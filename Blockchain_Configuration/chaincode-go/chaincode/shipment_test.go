@@ -0,0 +1,207 @@
+package chaincode
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// shipLots harvests lots A and B and plans shipment S1 of both from the farmer to the wholesaler
+func shipLots(ledger *testLedger) {
+	ledger.harvest("A", 100)
+	ledger.harvest("B", 50)
+	ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+		return ledger.contract.CreateShipment(ctx, fmt.Sprintf(
+			`{"id": "S1", "carrier": "Kumasi Haulage", "origin": "Techiman", "destination": "Accra", "receiverId": %q, "assetIds": ["A", "B"]}`,
+			testWholesaler))
+	})
+}
+
+func dispatch(ledger *testLedger) {
+	ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+		return ledger.contract.DispatchShipment(ctx, "S1")
+	})
+}
+
+func recall(ledger *testLedger, id string) {
+	ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+		return ledger.contract.RecallAsset(ctx, id, "listeria")
+	})
+}
+
+func TestShipmentLifecycle(t *testing.T) {
+	cancel := func(ledger *testLedger) error {
+		return ledger.submit(farmerClient, nil, func(ctx contractapi.TransactionContextInterface) error {
+			return ledger.contract.CancelShipment(ctx, "S1", "truck broke down")
+		})
+	}
+	receive := func(ledger *testLedger) error {
+		return ledger.submit(wholesalerClient, nil, func(ctx contractapi.TransactionContextInterface) error {
+			return ledger.contract.ReceiveShipment(ctx, "S1")
+		})
+	}
+
+	tests := []struct {
+		name        string
+		setup       func(*testLedger)
+		action      func(*testLedger) error
+		wantErr     string
+		wantStatus  string
+		wantDropped []string
+		wantEvent   string
+		wantLots    map[string]string
+	}{
+		{
+			name:       "sender cancels a planned shipment",
+			setup:      shipLots,
+			action:     cancel,
+			wantStatus: ShipmentCancelled,
+			wantEvent:  EventShipmentCancelled,
+			wantLots:   map[string]string{"A": StatusHarvested, "B": StatusHarvested},
+		},
+		{
+			name:       "sender cancels a shipment in transit",
+			setup:      func(ledger *testLedger) { shipLots(ledger); dispatch(ledger) },
+			action:     cancel,
+			wantStatus: ShipmentCancelled,
+			wantEvent:  EventShipmentCancelled,
+			wantLots:   map[string]string{"A": StatusHarvested, "B": StatusHarvested},
+		},
+		{
+			name:  "receiver cannot cancel",
+			setup: shipLots,
+			action: func(ledger *testLedger) error {
+				return ledger.submit(wholesalerClient, nil, func(ctx contractapi.TransactionContextInterface) error {
+					return ledger.contract.CancelShipment(ctx, "S1", "")
+				})
+			},
+			wantErr:    "not authorized to cancel shipment S1",
+			wantStatus: ShipmentPlanned,
+		},
+		{
+			name:       "a delivered shipment cannot be cancelled",
+			setup:      func(ledger *testLedger) { shipLots(ledger); dispatch(ledger); receive(ledger) },
+			action:     cancel,
+			wantErr:    "is Delivered and cannot be cancelled",
+			wantStatus: ShipmentDelivered,
+		},
+		{
+			name:       "receive hands over every lot",
+			setup:      func(ledger *testLedger) { shipLots(ledger); dispatch(ledger) },
+			action:     receive,
+			wantStatus: ShipmentDelivered,
+			wantEvent:  EventShipmentReceived,
+			wantLots:   map[string]string{"A": StatusWithWholesaler, "B": StatusWithWholesaler},
+		},
+		{
+			name:        "receive drops a lot recalled in transit",
+			setup:       func(ledger *testLedger) { shipLots(ledger); dispatch(ledger); recall(ledger, "A") },
+			action:      receive,
+			wantStatus:  ShipmentDelivered,
+			wantDropped: []string{"A"},
+			wantEvent:   EventShipmentReceived,
+			wantLots:    map[string]string{"A": StatusRecalled, "B": StatusWithWholesaler},
+		},
+		{
+			name: "receive cancels a shipment whose lots were all recalled",
+			setup: func(ledger *testLedger) {
+				shipLots(ledger)
+				dispatch(ledger)
+				recall(ledger, "A")
+				recall(ledger, "B")
+			},
+			action:      receive,
+			wantStatus:  ShipmentCancelled,
+			wantDropped: []string{"A", "B"},
+			wantEvent:   EventShipmentCancelled,
+			wantLots:    map[string]string{"A": StatusRecalled, "B": StatusRecalled},
+		},
+		{
+			name: "dispatch refuses a shipment whose lots were all recalled",
+			setup: func(ledger *testLedger) {
+				shipLots(ledger)
+				recall(ledger, "A")
+				recall(ledger, "B")
+			},
+			action: func(ledger *testLedger) error {
+				return ledger.submit(farmerClient, nil, func(ctx contractapi.TransactionContextInterface) error {
+					return ledger.contract.DispatchShipment(ctx, "S1")
+				})
+			},
+			wantErr:    "cancel it instead",
+			wantStatus: ShipmentPlanned,
+		},
+		{
+			name:  "a lot on an open shipment keeps its status",
+			setup: shipLots,
+			action: func(ledger *testLedger) error {
+				return ledger.submit(farmerClient, nil, func(ctx contractapi.TransactionContextInterface) error {
+					return ledger.contract.UpdateAssetStatus(ctx, "A", StatusDestroyed)
+				})
+			},
+			wantErr:    "is on shipment S1",
+			wantStatus: ShipmentPlanned,
+			wantLots:   map[string]string{"A": StatusHarvested},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newTestLedger(t)
+			tt.setup(ledger)
+			events := len(ledger.events)
+
+			err := tt.action(ledger)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+			if tt.wantEvent != "" && (len(ledger.events) == events || ledger.lastEvent() != tt.wantEvent) {
+				t.Errorf("got event %q, want %q", ledger.lastEvent(), tt.wantEvent)
+			}
+
+			var shipment *Shipment
+			ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+				shipment, err = ledger.contract.GetShipment(ctx, "S1")
+				return err
+			})
+			if shipment.Status != tt.wantStatus {
+				t.Errorf("got shipment status %s, want %s", shipment.Status, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(shipment.DroppedIDs, tt.wantDropped) {
+				t.Errorf("got dropped lots %v, want %v", shipment.DroppedIDs, tt.wantDropped)
+			}
+			for id, status := range tt.wantLots {
+				asset := ledger.asset(id)
+				if asset.Status != status {
+					t.Errorf("got status %s for asset %s, want %s", asset.Status, id, status)
+				}
+				if isCustodyStatus(status) && asset.CurrentOwnerId != partyID(asset, status) {
+					t.Errorf("asset %s is held by %q, want %q", id, asset.CurrentOwnerId, partyID(asset, status))
+				}
+			}
+		})
+	}
+}
+
+func TestCancelledShipmentReleasesLots(t *testing.T) {
+	ledger := newTestLedger(t)
+	shipLots(ledger)
+	ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+		return ledger.contract.CancelShipment(ctx, "S1", "")
+	})
+
+	// Once the shipment is closed the lots can be offered and have their status changed again
+	ledger.transfer(farmerClient, wholesalerClient, "A", testWholesaler)
+	if asset := ledger.asset("A"); asset.Status != StatusWithWholesaler {
+		t.Errorf("got status %s for asset A, want %s", asset.Status, StatusWithWholesaler)
+	}
+	ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+		return ledger.contract.UpdateAssetStatus(ctx, "B", StatusDestroyed)
+	})
+}
@@ -3,6 +3,7 @@ package chaincode
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
@@ -12,81 +13,122 @@ type SmartContract struct {
 	contractapi.Contract
 }
 
-// Asset represents the structure for an asset on the ledger
+// assetDocType marks asset documents in the state database so rich queries can tell them apart from other records
+const assetDocType = "asset"
+
+// Asset represents the structure for an asset on the ledger.
+// Dates are RFC3339 in UTC. Prices are kept in private data collections and the asset holds only their hashes.
 type Asset struct {
-	ID                string `json:"ID"`
-	FarmerId          string `json:"FarmerId"`
-	FarmerName        string `json:"FarmerName"`
-	FarmLocation      string `json:"FarmLocation"`
-	Variety           string `json:"Variety"`
-	BatchNo           string `json:"BatchNo"`
-	HarvestDate       string `json:"HarvestDate"`
-	Price             string `json:"Price"`
-	Quantity          string `json:"Quantity"`
-	WholesalerId      string `json:"WholesalerId"`
-	WholesalerName    string `json:"WholesalerName"`
-	WholesalerBuyDate string `json:"WholesalerBuyDate"`
-	RetailerId        string `json:"RetailerId"`
-	RetailerName      string `json:"RetailerName"`
-	RetailerBuyDate   string `json:"RetailerBuyDate"`
+	ID                 string   `json:"ID"`
+	FarmerId           string   `json:"FarmerId"`
+	FarmerName         string   `json:"FarmerName"`
+	FarmLocation       string   `json:"FarmLocation"`
+	Variety            string   `json:"Variety"`
+	BatchNo            string   `json:"BatchNo"`
+	HarvestDate        string   `json:"HarvestDate"`
+	PriceHash          string   `json:"PriceHash"`
+	Quantity           float64  `json:"Quantity"`
+	RemainingQuantity  float64  `json:"RemainingQuantity"`
+	Unit               string   `json:"Unit"`
+	WholesalerId       string   `json:"WholesalerId"`
+	WholesalerName     string   `json:"WholesalerName"`
+	WholesalerBuyDate  string   `json:"WholesalerBuyDate"`
+	WholesalePriceHash string   `json:"WholesalePriceHash"`
+	RetailerId         string   `json:"RetailerId"`
+	RetailerName       string   `json:"RetailerName"`
+	RetailerBuyDate    string   `json:"RetailerBuyDate"`
+	RetailPriceHash    string   `json:"RetailPriceHash"`
+	CurrentOwnerId     string   `json:"CurrentOwnerId"`
+	CurrentOwnerMSP    string   `json:"CurrentOwnerMSP"`
+	Status             string   `json:"Status"`
+	ParentID           string   `json:"ParentID"`
+	SourceIDs          []string `json:"SourceIDs,omitempty" metadata:",optional"`
+	ChildIDs           []string `json:"ChildIDs,omitempty" metadata:",optional"`
+	QualityFlags       []string `json:"QualityFlags,omitempty" metadata:",optional"`
+	RecallReason       string   `json:"RecallReason,omitempty" metadata:",optional"`
+	LastUpdatedBy      string   `json:"LastUpdatedBy"`
+	DocType            string   `json:"DocType"`
 }
 
 // InitLedger initializes the ledger with a set of sample assets
 func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
+	err := requireMSP(ctx, "create assets", FarmerMSP)
+	if err != nil {
+		return err
+	}
+
 	assets := []Asset{
-		{ID: "1", FarmerId: "1", FarmerName: "Farmer 1", FarmLocation: "Location 1", Variety: "Variety 1", BatchNo: "Batch 1", HarvestDate: "2021-01-01", Price: "100", Quantity: "100", WholesalerId: "2", WholesalerName: "Wholesaler 1", WholesalerBuyDate: "2021-01-02", RetailerId: "3", RetailerName: "Retailer 1", RetailerBuyDate: "2021-01-03"},
-		{ID: "2", FarmerId: "2", FarmerName: "Farmer 2", FarmLocation: "Location 2", Variety: "Variety 2", BatchNo: "Batch 2", HarvestDate: "2021-02-01", Price: "200", Quantity: "200", WholesalerId: "3", WholesalerName: "Wholesaler 2", WholesalerBuyDate: "2021-02-02", RetailerId: "4", RetailerName: "Retailer 2", RetailerBuyDate: "2021-02-03"},
-		{ID: "3", FarmerId: "3", FarmerName: "Farmer 3", FarmLocation: "Location 3", Variety: "Variety 3", BatchNo: "Batch 3", HarvestDate: "2021-03-01", Price: "300", Quantity: "300", WholesalerId: "4", WholesalerName: "Wholesaler 3", WholesalerBuyDate: "2021-03-02", RetailerId: "5", RetailerName: "Retailer 3", RetailerBuyDate: "2021-03-03"},
+		{ID: "1", FarmerId: "1", FarmerName: "Farmer 1", FarmLocation: "Location 1", Variety: "Variety 1", BatchNo: "Batch 1", HarvestDate: "2021-01-01T00:00:00Z", Quantity: 100, Unit: UnitKg, WholesalerId: "2", WholesalerName: "Wholesaler 1", WholesalerBuyDate: "2021-01-02T00:00:00Z", RetailerId: "3", RetailerName: "Retailer 1", RetailerBuyDate: "2021-01-03T00:00:00Z"},
+		{ID: "2", FarmerId: "2", FarmerName: "Farmer 2", FarmLocation: "Location 2", Variety: "Variety 2", BatchNo: "Batch 2", HarvestDate: "2021-02-01T00:00:00Z", Quantity: 200, Unit: UnitKg, WholesalerId: "3", WholesalerName: "Wholesaler 2", WholesalerBuyDate: "2021-02-02T00:00:00Z", RetailerId: "4", RetailerName: "Retailer 2", RetailerBuyDate: "2021-02-03T00:00:00Z"},
+		{ID: "3", FarmerId: "3", FarmerName: "Farmer 3", FarmLocation: "Location 3", Variety: "Variety 3", BatchNo: "Batch 3", HarvestDate: "2021-03-01T00:00:00Z", Quantity: 300, Unit: UnitKg, WholesalerId: "4", WholesalerName: "Wholesaler 3", WholesalerBuyDate: "2021-03-02T00:00:00Z", RetailerId: "5", RetailerName: "Retailer 3", RetailerBuyDate: "2021-03-03T00:00:00Z"},
 	}
 
+	var ids []string
 	for _, asset := range assets {
-		assetJSON, err := json.Marshal(asset)
-		if err != nil {
-			return err
-		}
+		asset.Status = deriveStatus(&asset)
+		deriveCustody(&asset)
 
-		err = ctx.GetStub().PutState(asset.ID, assetJSON)
+		err = putAsset(ctx, &asset)
 		if err != nil {
-			return fmt.Errorf("failed to put asset %s: %v", asset.ID, err)
+			return err
 		}
+		ids = append(ids, asset.ID)
 	}
 
-	return nil
+	return setEvent(ctx, &AssetEvent{Type: EventLedgerInitialized, RelatedIDs: ids})
 }
 
-// CreateAsset creates a new asset and stores it in the ledger
-func (s *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface, id, farmerId, farmerName, farmLocation, variety, batchNo, harvestDate, price, quantity, wholesalerId, WholesalerName, wholesalerPrice, wholesalerBuyDate, retailerId, retailerName, retailerBuyDate string) error {
-	exists, err := s.AssetExists(ctx, id)
+// CreateAsset creates a new harvest lot from a HarvestRecord JSON payload and stores it in the ledger.
+// The farmer's price, if any, is passed in the transient map under "price".
+func (s *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface, harvestJSON string) error {
+	err := requireMSP(ctx, "create assets", FarmerMSP)
+	if err != nil {
+		return err
+	}
+
+	var harvest HarvestRecord
+	err = decodePayload(harvestJSON, &harvest)
+	if err != nil {
+		return err
+	}
+	err = harvest.normalize()
+	if err != nil {
+		return err
+	}
+	err = harvest.validate()
+	if err != nil {
+		return err
+	}
+
+	exists, err := s.AssetExists(ctx, harvest.ID)
 	if err != nil {
 		return err
 	}
 	if exists {
-		return fmt.Errorf("the asset %s already exists", id)
+		return fmt.Errorf("the asset %s already exists", harvest.ID)
 	}
 
 	asset := Asset{
-		ID:                id,
-		FarmerId:          farmerId,
-		FarmerName:        farmerName,
-		FarmLocation:      farmLocation,
-		Variety:           variety,
-		BatchNo:           batchNo,
-		HarvestDate:       harvestDate,
-		Price:             price,
-		Quantity:          quantity,
-		WholesalerId:      wholesalerId,
-		WholesalerName:    WholesalerName,
-		WholesalerBuyDate: wholesalerBuyDate,
-		RetailerId:        retailerId,
-		RetailerName:      retailerName,
-		RetailerBuyDate:   retailerBuyDate,
+		ID:     harvest.ID,
+		Status: StatusHarvested,
 	}
-	assetJSON, err := json.Marshal(asset)
+	harvest.mergeInto(&asset)
+	err = fillFarmer(ctx, &asset)
+	if err != nil {
+		return err
+	}
+	takeCustody(&asset, asset.FarmerId, FarmerMSP)
+	asset.RemainingQuantity = asset.Quantity
+	err = applyPrice(ctx, &asset, PriceHarvest, &asset.PriceHash)
+	if err != nil {
+		return err
+	}
+	err = emitAssetEvent(ctx, EventAssetCreated, &asset)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, assetJSON)
+	return putAsset(ctx, &asset)
 }
 
 // ReadAsset retrieves an asset from the ledger by its ID
@@ -99,48 +141,199 @@ func (s *SmartContract) ReadAsset(ctx contractapi.TransactionContextInterface, i
 		return nil, fmt.Errorf("the asset %s does not exist", id)
 	}
 
-	var asset Asset
-	err = json.Unmarshal(assetJSON, &asset)
+	return unmarshalAsset(assetJSON)
+}
+
+// RecordHarvest merges a HarvestRecord JSON payload into a lot that is still with the farmer.
+// A new price, if any, is passed in the transient map under "price".
+func (s *SmartContract) RecordHarvest(ctx contractapi.TransactionContextInterface, harvestJSON string) error {
+	var harvest HarvestRecord
+	err := decodePayload(harvestJSON, &harvest)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	err = harvest.normalize()
+	if err != nil {
+		return err
 	}
 
-	return &asset, nil
+	asset, err := s.ReadAsset(ctx, harvest.ID)
+	if err != nil {
+		return err
+	}
+	err = requireMSP(ctx, "modify farmer details of asset "+asset.ID, FarmerMSP)
+	if err != nil {
+		return err
+	}
+	if asset.Status != StatusHarvested {
+		return fmt.Errorf("the asset %s is %s and its harvest details can no longer be changed", asset.ID, asset.Status)
+	}
+
+	// A corrected quantity changes what is left by the same amount, since part of the lot may have been
+	// transferred already
+	previousQuantity := asset.Quantity
+	harvest.mergeInto(asset)
+	asset.RemainingQuantity += asset.Quantity - previousQuantity
+	if asset.RemainingQuantity < -quantityTolerance {
+		return fmt.Errorf("the asset %s has already transferred more than %g %s", asset.ID, asset.Quantity, asset.Unit)
+	}
+	err = fillFarmer(ctx, asset)
+	if err != nil {
+		return err
+	}
+	takeCustody(asset, asset.FarmerId, FarmerMSP)
+	err = applyPrice(ctx, asset, PriceHarvest, &asset.PriceHash)
+	if err != nil {
+		return err
+	}
+	err = emitAssetEvent(ctx, EventHarvestUpdated, asset)
+	if err != nil {
+		return err
+	}
+
+	return putAsset(ctx, asset)
 }
 
-// UpdateAsset updates an existing asset in the ledger
-func (s *SmartContract) UpdateAsset(ctx contractapi.TransactionContextInterface, id, farmerId, farmerName, farmLocation, variety, batchNo, harvestDate, price, quantity, wholesalerId, WholesalerName, wholesalerPrice, wholesalerBuyDate, retailerId, retailerName, retailerBuyDate string) error {
-	exists, err := s.AssetExists(ctx, id)
+// RecordWholesalePurchase merges a WholesalePurchase JSON payload into a lot in wholesaler custody to correct the
+// purchase details. Lots reach the wholesaler through OfferTransfer and AcceptTransfer, so the wholesaler itself
+// cannot be changed here. A corrected price, if any, is passed in the transient map under "price".
+func (s *SmartContract) RecordWholesalePurchase(ctx contractapi.TransactionContextInterface, purchaseJSON string) error {
+	var purchase WholesalePurchase
+	err := decodePayload(purchaseJSON, &purchase)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return fmt.Errorf("the asset %s does not exist", id)
+	err = purchase.normalize()
+	if err != nil {
+		return err
 	}
 
-	asset := Asset{
-		ID:                id,
-		FarmerId:          farmerId,
-		FarmerName:        farmerName,
-		FarmLocation:      farmLocation,
-		Variety:           variety,
-		BatchNo:           batchNo,
-		HarvestDate:       harvestDate,
-		Price:             price,
-		Quantity:          quantity,
-		WholesalerId:      wholesalerId,
-		WholesalerName:    WholesalerName,
-		WholesalerBuyDate: wholesalerBuyDate,
-		RetailerId:        retailerId,
-		RetailerName:      retailerName,
-		RetailerBuyDate:   retailerBuyDate,
+	asset, err := s.ReadAsset(ctx, purchase.ID)
+	if err != nil {
+		return err
 	}
-	assetJSON, err := json.Marshal(asset)
+	err = requireMSP(ctx, "record wholesaler purchases of asset "+asset.ID, WholesalerMSP)
+	if err != nil {
+		return err
+	}
+	if asset.Status != StatusWithWholesaler {
+		return fmt.Errorf("the asset %s is %s; lots move to a wholesaler through OfferTransfer and AcceptTransfer", asset.ID, asset.Status)
+	}
+	if purchase.WholesalerId != "" && purchase.WholesalerId != asset.WholesalerId {
+		return fmt.Errorf("the wholesaler of asset %s can only change through a transfer", asset.ID)
+	}
+
+	purchase.mergeInto(asset)
+	if asset.WholesalerId == "" || asset.WholesalerBuyDate == "" {
+		return fmt.Errorf("wholesalerId and wholesalerBuyDate are required to record the purchase of asset %s", asset.ID)
+	}
+	err = fillWholesaler(ctx, asset)
+	if err != nil {
+		return err
+	}
+	err = applyPrice(ctx, asset, PriceWholesale, &asset.WholesalePriceHash)
+	if err != nil {
+		return err
+	}
+	err = emitAssetEvent(ctx, EventWholesalePurchased, asset)
+	if err != nil {
+		return err
+	}
+
+	return putAsset(ctx, asset)
+}
+
+// RecordRetailPurchase merges a RetailPurchase JSON payload into a lot in retailer custody to correct the
+// purchase details. Lots reach the retailer through OfferTransfer and AcceptTransfer, so the retailer itself
+// cannot be changed here. A corrected price, if any, is passed in the transient map under "price".
+func (s *SmartContract) RecordRetailPurchase(ctx contractapi.TransactionContextInterface, purchaseJSON string) error {
+	var purchase RetailPurchase
+	err := decodePayload(purchaseJSON, &purchase)
+	if err != nil {
+		return err
+	}
+	err = purchase.normalize()
+	if err != nil {
+		return err
+	}
+
+	asset, err := s.ReadAsset(ctx, purchase.ID)
+	if err != nil {
+		return err
+	}
+	err = requireMSP(ctx, "record retailer purchases of asset "+asset.ID, RetailerMSP)
+	if err != nil {
+		return err
+	}
+	if asset.Status != StatusWithRetailer {
+		return fmt.Errorf("the asset %s is %s; lots move to a retailer through OfferTransfer and AcceptTransfer", asset.ID, asset.Status)
+	}
+	if purchase.RetailerId != "" && purchase.RetailerId != asset.RetailerId {
+		return fmt.Errorf("the retailer of asset %s can only change through a transfer", asset.ID)
+	}
+
+	purchase.mergeInto(asset)
+	if asset.RetailerId == "" || asset.RetailerBuyDate == "" {
+		return fmt.Errorf("retailerId and retailerBuyDate are required to record the purchase of asset %s", asset.ID)
+	}
+	err = fillRetailer(ctx, asset)
+	if err != nil {
+		return err
+	}
+	err = applyPrice(ctx, asset, PriceRetail, &asset.RetailPriceHash)
+	if err != nil {
+		return err
+	}
+	err = emitAssetEvent(ctx, EventRetailPurchased, asset)
+	if err != nil {
+		return err
+	}
+
+	return putAsset(ctx, asset)
+}
+
+// UpdateAssetStatus moves an asset to a new lifecycle status, such as Sold, Consumed or Destroyed, after which
+// nobody holds it. Changes are made by the organization holding the asset. Custody moves through OfferTransfer
+// and AcceptTransfer, and recalls go through RecallAsset so derived lots are recalled too. A lot on an open
+// shipment keeps its status until the shipment is delivered or cancelled.
+func (s *SmartContract) UpdateAssetStatus(ctx contractapi.TransactionContextInterface, id, status string) error {
+	if status == StatusRecalled {
+		return fmt.Errorf("asset %s must be recalled with RecallAsset so the lots derived from it are recalled too", id)
+	}
+	if isCustodyStatus(status) {
+		return fmt.Errorf("asset %s changes hands through OfferTransfer and AcceptTransfer", id)
+	}
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = requireMSP(ctx, "change the status of asset "+id, custodianMSP(deriveStatus(asset)))
+	if err != nil {
+		return err
+	}
+	if err := checkTransition(id, asset.Status, status); err != nil {
+		return err
+	}
+	shipment, err := openShipment(ctx, asset.ID)
+	if err != nil {
+		return err
+	}
+	if shipment != nil {
+		return fmt.Errorf("the asset %s is on shipment %s; deliver or cancel it first", asset.ID, shipment.ID)
+	}
+	asset.Status = status
+	releaseCustody(asset)
+
+	eventType, ok := statusEvents[status]
+	if !ok {
+		eventType = EventAssetStatusChanged
+	}
+	err = emitAssetEvent(ctx, eventType, asset)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, assetJSON)
+	return putAsset(ctx, asset)
 }
 
 // AssetExists checks if an asset exists in the ledger
@@ -168,13 +361,164 @@ func (s *SmartContract) GetAllAssets(ctx contractapi.TransactionContextInterface
 			return nil, err
 		}
 
-		var asset Asset
-		err = json.Unmarshal(queryResponse.Value, &asset)
+		asset, err := unmarshalAsset(queryResponse.Value)
 		if err != nil {
 			return nil, err
 		}
-		assets = append(assets, &asset)
+		assets = append(assets, asset)
 	}
 
 	return assets, nil
 }
+
+// storedAsset reads an asset written by an earlier version of the chaincode, whose quantity may be a
+// free-form string and whose price may still be public
+type storedAsset struct {
+	Asset
+	Price    json.RawMessage `json:"Price"`
+	Currency string          `json:"Currency"`
+	Quantity json.RawMessage `json:"Quantity"`
+	// RemainingQuantity is missing on assets written before holders were stored
+	RemainingQuantity *float64 `json:"RemainingQuantity"`
+}
+
+// unmarshalAsset decodes a stored asset. Free-form quantities are converted where they can be parsed, and
+// the status and holder are derived if the asset predates the fields that store them.
+func unmarshalAsset(assetJSON []byte) (*Asset, error) {
+	var stored storedAsset
+	err := json.Unmarshal(assetJSON, &stored)
+	if err != nil {
+		return nil, err
+	}
+
+	asset := stored.Asset
+	_, err = stored.decodeQuantity(&asset)
+	if err != nil {
+		return nil, fmt.Errorf("the asset %s is stored in a format that cannot be converted: %v", asset.ID, err)
+	}
+	if asset.Status == "" {
+		asset.Status = deriveStatus(&asset)
+	}
+	stored.decodeCustody(&asset)
+
+	return &asset, nil
+}
+
+// decodeQuantity fills in the quantity of an asset, converting a free-form string and taking the unit from
+// it when it names one. It reports whether a string had to be converted.
+func (stored *storedAsset) decodeQuantity(asset *Asset) (bool, error) {
+	var quantity string
+	if json.Unmarshal(stored.Quantity, &quantity) == nil {
+		if strings.TrimSpace(quantity) == "" {
+			return true, nil
+		}
+		amount, unit, err := parseLegacyQuantity(quantity)
+		if err != nil {
+			return true, err
+		}
+		asset.Quantity = amount
+		if asset.Unit == "" {
+			asset.Unit = unit
+		}
+		return true, nil
+	}
+	if len(stored.Quantity) > 0 {
+		err := json.Unmarshal(stored.Quantity, &asset.Quantity)
+		if err != nil {
+			return false, fmt.Errorf("invalid quantity: %v", err)
+		}
+	}
+
+	return false, nil
+}
+
+// decodeCustody fills in the remaining quantity of an asset, deriving it and the holder when the asset
+// predates them. It reports whether they had to be derived.
+func (stored *storedAsset) decodeCustody(asset *Asset) bool {
+	if stored.RemainingQuantity == nil {
+		deriveCustody(asset)
+		return true
+	}
+	asset.RemainingQuantity = *stored.RemainingQuantity
+
+	return false
+}
+
+// decodePrice returns a price still held on the public asset, converting a free-form string and taking the
+// currency from it when it names one. It reports whether the asset holds a Price field at all.
+func (stored *storedAsset) decodePrice() (*PriceRecord, bool, error) {
+	if len(stored.Price) == 0 || string(stored.Price) == "null" {
+		return nil, false, nil
+	}
+
+	price := &PriceRecord{ID: stored.ID, Kind: PriceHarvest, Currency: stored.Currency}
+	var text string
+	if json.Unmarshal(stored.Price, &text) == nil {
+		if strings.TrimSpace(text) == "" {
+			return nil, true, nil
+		}
+		minor, currency, err := parseLegacyPrice(text)
+		if err != nil {
+			return nil, true, err
+		}
+		price.Price = minor
+		if price.Currency == "" {
+			price.Currency = currency
+		}
+		return price, true, nil
+	}
+
+	err := json.Unmarshal(stored.Price, &price.Price)
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid price: %v", err)
+	}
+	if price.Price == 0 && price.Currency == "" {
+		return nil, true, nil
+	}
+
+	return price, true, nil
+}
+
+// readStoredAsset returns the asset currently stored under an ID, or nil if there is none
+func readStoredAsset(ctx contractapi.TransactionContextInterface, id string) (*Asset, error) {
+	assetJSON, err := ctx.GetStub().GetState(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read asset %s from world state: %v", id, err)
+	}
+	if assetJSON == nil {
+		return nil, nil
+	}
+
+	return unmarshalAsset(assetJSON)
+}
+
+// putAsset writes an asset to the world state, recording the MSP of the client making the change
+// and keeping its index entries up to date
+func putAsset(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return err
+	}
+	asset.LastUpdatedBy = mspID
+	asset.DocType = assetDocType
+
+	assetJSON, err := json.Marshal(asset)
+	if err != nil {
+		return err
+	}
+
+	previous, err := readStoredAsset(ctx, asset.ID)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(asset.ID, assetJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put asset %s: %v", asset.ID, err)
+	}
+	err = recordWriter(ctx, asset.ID, mspID)
+	if err != nil {
+		return err
+	}
+
+	return updateAssetIndexes(ctx, previous, asset)
+}
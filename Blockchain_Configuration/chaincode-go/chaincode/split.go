@@ -0,0 +1,123 @@
+package chaincode
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// SplitRequest is the payload for dividing a lot into sub-lots
+type SplitRequest struct {
+	ID       string   `json:"id"`
+	Children []SubLot `json:"children"`
+}
+
+// SubLot is one part of a split, identified by a new asset ID
+type SubLot struct {
+	ID       string  `json:"id"`
+	Quantity float64 `json:"quantity"`
+}
+
+// quantityTolerance absorbs floating point rounding when comparing quantities
+const quantityTolerance = 1e-9
+
+// SplitAsset divides a lot into sub-lots with new IDs that keep a link to it through ParentID.
+// The sub-lot quantities must add up to the quantity remaining in the lot, which is then marked Consumed.
+// Prices stay recorded against the lot they were agreed for.
+func (s *SmartContract) SplitAsset(ctx contractapi.TransactionContextInterface, splitJSON string) error {
+	var split SplitRequest
+	err := decodePayload(splitJSON, &split)
+	if err != nil {
+		return err
+	}
+	if len(split.Children) < 2 {
+		return fmt.Errorf("a split of asset %s needs at least two sub-lots", split.ID)
+	}
+
+	parent, err := s.ReadAsset(ctx, split.ID)
+	if err != nil {
+		return err
+	}
+	if !isCustodyStatus(parent.Status) {
+		return fmt.Errorf("the asset %s is %s and cannot be split", parent.ID, parent.Status)
+	}
+	err = requireMSP(ctx, "split asset "+parent.ID, custodianMSP(parent.Status))
+	if err != nil {
+		return err
+	}
+	err = checkTransition(parent.ID, parent.Status, StatusConsumed)
+	if err != nil {
+		return err
+	}
+	err = checkNotCommitted(ctx, parent.ID)
+	if err != nil {
+		return err
+	}
+
+	remaining := parent.RemainingQuantity
+	if remaining <= 0 {
+		return fmt.Errorf("the asset %s has no quantity to split", parent.ID)
+	}
+
+	total := 0.0
+	seen := make(map[string]bool)
+	for _, child := range split.Children {
+		if child.ID == "" {
+			return fmt.Errorf("invalid payload: every sub-lot needs an id")
+		}
+		if seen[child.ID] {
+			return fmt.Errorf("invalid payload: sub-lot %s is listed more than once", child.ID)
+		}
+		seen[child.ID] = true
+
+		exists, err := s.AssetExists(ctx, child.ID)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("the asset %s already exists", child.ID)
+		}
+
+		if child.Quantity <= 0 {
+			return fmt.Errorf("invalid payload: the quantity of sub-lot %s must be greater than zero", child.ID)
+		}
+		total += child.Quantity
+	}
+	if math.Abs(total-remaining) > quantityTolerance {
+		return fmt.Errorf("sub-lot quantities add up to %g %s but asset %s has %g %s remaining",
+			total, parent.Unit, parent.ID, remaining, parent.Unit)
+	}
+
+	for _, child := range split.Children {
+		subLot := *parent
+		subLot.ID = child.ID
+		subLot.Quantity = child.Quantity
+		subLot.RemainingQuantity = child.Quantity
+		subLot.ParentID = parent.ID
+		subLot.SourceIDs = nil
+		subLot.ChildIDs = nil
+		subLot.PriceHash = ""
+		subLot.WholesalePriceHash = ""
+		subLot.RetailPriceHash = ""
+
+		err = putAsset(ctx, &subLot)
+		if err != nil {
+			return err
+		}
+		parent.ChildIDs = append(parent.ChildIDs, child.ID)
+	}
+	parent.Status = StatusConsumed
+	releaseCustody(parent)
+
+	var childIDs []string
+	for _, child := range split.Children {
+		childIDs = append(childIDs, child.ID)
+	}
+	err = emitAssetEvent(ctx, EventAssetSplit, parent, childIDs...)
+	if err != nil {
+		return err
+	}
+
+	return putAsset(ctx, parent)
+}
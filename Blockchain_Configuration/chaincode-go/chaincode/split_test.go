@@ -0,0 +1,138 @@
+package chaincode
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestSplitAsset(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(*testLedger)
+		client  testIdentity
+		split   string
+		wantErr string
+	}{
+		{
+			name:   "split into sub-lots adding up to the lot",
+			client: farmerClient,
+			split:  `{"id": "A", "children": [{"id": "A1", "quantity": 60}, {"id": "A2", "quantity": 40}]}`,
+		},
+		{
+			name:    "sub-lots short of the lot",
+			client:  farmerClient,
+			split:   `{"id": "A", "children": [{"id": "A1", "quantity": 60}, {"id": "A2", "quantity": 30}]}`,
+			wantErr: "add up to 90 kg but asset A has 100 kg remaining",
+		},
+		{
+			name:    "a single sub-lot",
+			client:  farmerClient,
+			split:   `{"id": "A", "children": [{"id": "A1", "quantity": 100}]}`,
+			wantErr: "needs at least two sub-lots",
+		},
+		{
+			name:    "a sub-lot listed twice",
+			client:  farmerClient,
+			split:   `{"id": "A", "children": [{"id": "A1", "quantity": 50}, {"id": "A1", "quantity": 50}]}`,
+			wantErr: "listed more than once",
+		},
+		{
+			name:    "a sub-lot ID already in use",
+			setup:   func(ledger *testLedger) { ledger.harvest("B", 10) },
+			client:  farmerClient,
+			split:   `{"id": "A", "children": [{"id": "A1", "quantity": 50}, {"id": "B", "quantity": 50}]}`,
+			wantErr: "the asset B already exists",
+		},
+		{
+			name:    "an organization not holding the lot",
+			client:  wholesalerClient,
+			split:   `{"id": "A", "children": [{"id": "A1", "quantity": 50}, {"id": "A2", "quantity": 50}]}`,
+			wantErr: "not authorized to split asset A",
+		},
+		{
+			name: "a lot with an open offer",
+			setup: func(ledger *testLedger) {
+				ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+					_, err := ledger.contract.OfferTransfer(ctx, fmt.Sprintf(`{"assetId": "A", "buyerId": %q, "quantity": 100}`, testWholesaler))
+					return err
+				})
+			},
+			client:  farmerClient,
+			split:   `{"id": "A", "children": [{"id": "A1", "quantity": 50}, {"id": "A2", "quantity": 50}]}`,
+			wantErr: "already has an open offer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newTestLedger(t)
+			ledger.harvest("A", 100)
+			if tt.setup != nil {
+				tt.setup(ledger)
+			}
+
+			err := ledger.submit(tt.client, nil, func(ctx contractapi.TransactionContextInterface) error {
+				return ledger.contract.SplitAsset(ctx, tt.split)
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				if parent := ledger.asset("A"); parent.Status != StatusHarvested || parent.RemainingQuantity != 100 {
+					t.Errorf("a rejected split left the lot %s with %g remaining", parent.Status, parent.RemainingQuantity)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			parent := ledger.asset("A")
+			if parent.Status != StatusConsumed || parent.RemainingQuantity != 0 || parent.CurrentOwnerId != "" {
+				t.Errorf("got parent %s with %g remaining held by %q, want it consumed", parent.Status, parent.RemainingQuantity, parent.CurrentOwnerId)
+			}
+			if !reflect.DeepEqual(parent.ChildIDs, []string{"A1", "A2"}) {
+				t.Errorf("got child IDs %v", parent.ChildIDs)
+			}
+			for id, quantity := range map[string]float64{"A1": 60, "A2": 40} {
+				child := ledger.asset(id)
+				if child.ParentID != "A" || child.Quantity != quantity || child.RemainingQuantity != quantity {
+					t.Errorf("got sub-lot %s of %s with %g of %g, want %g from A", id, child.ParentID, child.RemainingQuantity, child.Quantity, quantity)
+				}
+				if child.Status != StatusHarvested || child.CurrentOwnerId != testFarmer {
+					t.Errorf("got sub-lot %s %s held by %q", id, child.Status, child.CurrentOwnerId)
+				}
+			}
+			if ledger.lastEvent() != EventAssetSplit {
+				t.Errorf("got event %q, want %q", ledger.lastEvent(), EventAssetSplit)
+			}
+		})
+	}
+}
+
+func TestSplitMergedAsset(t *testing.T) {
+	ledger := newTestLedger(t)
+	ledger.harvest("A", 100)
+	ledger.harvest("B", 50)
+	ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+		return ledger.contract.MergeAssets(ctx, `{"id": "M", "sourceIds": ["A", "B"]}`)
+	})
+	ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+		return ledger.contract.SplitAsset(ctx, `{"id": "M", "children": [{"id": "M1", "quantity": 90}, {"id": "M2", "quantity": 60}]}`)
+	})
+
+	// Sub-lots trace back to the merged sources through their parent, not as merges of their own
+	for _, id := range []string{"M1", "M2"} {
+		child := ledger.asset(id)
+		if child.ParentID != "M" || child.SourceIDs != nil {
+			t.Errorf("got sub-lot %s of %q with sources %v, want it from M with none", id, child.ParentID, child.SourceIDs)
+		}
+	}
+	if merged := ledger.asset("M"); !reflect.DeepEqual(merged.SourceIDs, []string{"A", "B"}) {
+		t.Errorf("got merged lot sources %v", merged.SourceIDs)
+	}
+}
@@ -0,0 +1,282 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// telemetryObjectType is the composite key object type of cold-chain telemetry summaries
+const telemetryObjectType = "telemetry"
+
+// telemetryTransientKey is the transient map entry that carries a batch of sensor readings into a transaction
+const telemetryTransientKey = "telemetry"
+
+// TelemetryBatch is the JSON a client puts in the transient map under "telemetry". The readings stay off the
+// ledger; only their hash and a summary are stored.
+type TelemetryBatch struct {
+	DeviceID string             `json:"deviceId"`
+	Readings []TelemetryReading `json:"readings"`
+}
+
+// TelemetryReading is one sample from a temperature and humidity logger. Temperature is in °C and humidity
+// is relative humidity in percent.
+type TelemetryReading struct {
+	Timestamp   string   `json:"timestamp"`
+	Temperature *float64 `json:"temperature"`
+	Humidity    *float64 `json:"humidity"`
+}
+
+// TelemetryLimits are the storage conditions a variety must be kept in. An empty Variety holds the limits
+// for varieties that have none of their own.
+type TelemetryLimits struct {
+	Variety        string  `json:"variety"`
+	MinTemperature float64 `json:"minTemperature"`
+	MaxTemperature float64 `json:"maxTemperature"`
+	MinHumidity    float64 `json:"minHumidity"`
+	MaxHumidity    float64 `json:"maxHumidity"`
+}
+
+// TelemetrySummary is what the ledger keeps of a telemetry batch: the SHA-256 of the batch as submitted,
+// so the off-chain readings can be checked against it, and the figures needed to judge the cold chain.
+// SecondsOutOfRange counts the time from each reading outside the limits to the next reading.
+type TelemetrySummary struct {
+	ID                string          `json:"ID"`
+	AssetID           string          `json:"AssetID"`
+	DeviceID          string          `json:"DeviceID"`
+	BatchHash         string          `json:"BatchHash"`
+	ReadingCount      int             `json:"ReadingCount"`
+	Start             string          `json:"Start"`
+	End               string          `json:"End"`
+	MinTemperature    float64         `json:"MinTemperature"`
+	MaxTemperature    float64         `json:"MaxTemperature"`
+	MinHumidity       float64         `json:"MinHumidity"`
+	MaxHumidity       float64         `json:"MaxHumidity"`
+	SecondsOutOfRange int64           `json:"SecondsOutOfRange"`
+	Limits            TelemetryLimits `json:"Limits"`
+	Breached          bool            `json:"Breached"`
+	SubmitterMSP      string          `json:"SubmitterMSP"`
+	Timestamp         time.Time       `json:"Timestamp"`
+}
+
+// defaultTelemetryLimits apply to varieties without limits of their own. They are the usual storage
+// conditions for fresh tomatoes.
+var defaultTelemetryLimits = TelemetryLimits{
+	MinTemperature: 7,
+	MaxTemperature: 15,
+	MinHumidity:    85,
+	MaxHumidity:    95,
+}
+
+// varietyTelemetryLimits holds the limits of varieties that need storage conditions other than the default,
+// keyed by variety. Limits are part of the chaincode rather than a setting any organization can change, so
+// no holder of a lot can widen them to hide a breach; changing them takes a chaincode upgrade approved by
+// the channel's organizations.
+var varietyTelemetryLimits = map[string]TelemetryLimits{}
+
+// RecordTelemetry stores the summary of a batch of cold-chain sensor readings against a lot. The readings
+// are passed in the transient map under "telemetry". If any reading falls outside the limits for the lot's
+// variety the lot is flagged and a ColdChainBreach event is raised.
+func (s *SmartContract) RecordTelemetry(ctx contractapi.TransactionContextInterface, id string) (*TelemetrySummary, error) {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !isCustodyStatus(asset.Status) {
+		return nil, fmt.Errorf("the asset %s is %s and no longer in the cold chain", asset.ID, asset.Status)
+	}
+	err = requireMSP(ctx, "record telemetry for asset "+asset.ID, FarmerMSP, WholesalerMSP, RetailerMSP)
+	if err != nil {
+		return nil, err
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the transient map: %v", err)
+	}
+	batchJSON, ok := transientMap[telemetryTransientKey]
+	if !ok {
+		return nil, fmt.Errorf("the transient map has no %q entry", telemetryTransientKey)
+	}
+	var batch TelemetryBatch
+	err = decodePayload(string(batchJSON), &batch)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := telemetryLimits(asset.Variety)
+	summary, err := summarizeTelemetry(&batch, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	mspID, err := clientMSPID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+	hash := sha256.Sum256(batchJSON)
+	summary.ID = ctx.GetStub().GetTxID()
+	summary.AssetID = asset.ID
+	summary.DeviceID = batch.DeviceID
+	summary.BatchHash = hex.EncodeToString(hash[:])
+	summary.SubmitterMSP = mspID
+	summary.Timestamp = timestamp.AsTime()
+
+	key, err := ctx.GetStub().CreateCompositeKey(telemetryObjectType, []string{asset.ID, summary.ID})
+	if err != nil {
+		return nil, err
+	}
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.GetStub().PutState(key, summaryJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to put telemetry of asset %s: %v", asset.ID, err)
+	}
+
+	if !summary.Breached {
+		err = setEvent(ctx, &AssetEvent{Type: EventTelemetryRecorded, AssetID: asset.ID})
+		if err != nil {
+			return nil, err
+		}
+		return summary, nil
+	}
+
+	addQualityFlag(asset, fmt.Sprintf("telemetry %s: %g–%g °C and %g–%g%% humidity, outside %g–%g °C and %g–%g%% for %s",
+		summary.ID, summary.MinTemperature, summary.MaxTemperature, summary.MinHumidity, summary.MaxHumidity,
+		limits.MinTemperature, limits.MaxTemperature, limits.MinHumidity, limits.MaxHumidity,
+		time.Duration(summary.SecondsOutOfRange)*time.Second))
+	err = emitAssetEvent(ctx, EventColdChainBreach, asset)
+	if err != nil {
+		return nil, err
+	}
+	err = putAsset(ctx, asset)
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// GetTelemetry returns the telemetry summaries recorded for an asset, oldest first
+func (s *SmartContract) GetTelemetry(ctx contractapi.TransactionContextInterface, id string) ([]*TelemetrySummary, error) {
+	exists, err := s.AssetExists(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the asset %s does not exist", id)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(telemetryObjectType, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	summaries := []*TelemetrySummary{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var summary TelemetrySummary
+		err = json.Unmarshal(queryResponse.Value, &summary)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, &summary)
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].Timestamp.Before(summaries[j].Timestamp)
+	})
+
+	return summaries, nil
+}
+
+// GetTelemetryLimits returns the storage limits telemetry for a variety is checked against
+func (s *SmartContract) GetTelemetryLimits(ctx contractapi.TransactionContextInterface, variety string) (*TelemetryLimits, error) {
+	return telemetryLimits(variety), nil
+}
+
+// telemetryLimits returns the limits of a variety, or the default limits if it has none of its own
+func telemetryLimits(variety string) *TelemetryLimits {
+	limits, ok := varietyTelemetryLimits[variety]
+	if !ok {
+		limits = defaultTelemetryLimits
+	}
+
+	return &limits
+}
+
+// summarizeTelemetry checks a batch of readings and works out its summary against the given limits
+func summarizeTelemetry(batch *TelemetryBatch, limits *TelemetryLimits) (*TelemetrySummary, error) {
+	if batch.DeviceID == "" {
+		return nil, fmt.Errorf("invalid payload: deviceId is required")
+	}
+	if len(batch.Readings) == 0 {
+		return nil, fmt.Errorf("invalid payload: a telemetry batch needs at least one reading")
+	}
+
+	type sample struct {
+		at                    time.Time
+		temperature, humidity float64
+	}
+	samples := make([]sample, 0, len(batch.Readings))
+	for i, reading := range batch.Readings {
+		if reading.Temperature == nil || reading.Humidity == nil {
+			return nil, fmt.Errorf("invalid payload: reading %d needs a temperature and a humidity", i)
+		}
+		if *reading.Humidity < 0 || *reading.Humidity > 100 {
+			return nil, fmt.Errorf("invalid payload: reading %d has humidity outside 0–100%%", i)
+		}
+		date, err := normalizeDate(reading.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload: reading %d: %v", i, err)
+		}
+		at, _ := time.Parse(time.RFC3339, date)
+		samples = append(samples, sample{at, *reading.Temperature, *reading.Humidity})
+	}
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].at.Before(samples[j].at) })
+
+	summary := &TelemetrySummary{
+		ReadingCount:   len(samples),
+		Start:          samples[0].at.Format(time.RFC3339),
+		End:            samples[len(samples)-1].at.Format(time.RFC3339),
+		MinTemperature: math.Inf(1),
+		MaxTemperature: math.Inf(-1),
+		MinHumidity:    math.Inf(1),
+		MaxHumidity:    math.Inf(-1),
+		Limits:         *limits,
+	}
+	for i, s := range samples {
+		summary.MinTemperature = math.Min(summary.MinTemperature, s.temperature)
+		summary.MaxTemperature = math.Max(summary.MaxTemperature, s.temperature)
+		summary.MinHumidity = math.Min(summary.MinHumidity, s.humidity)
+		summary.MaxHumidity = math.Max(summary.MaxHumidity, s.humidity)
+
+		outOfRange := s.temperature < limits.MinTemperature || s.temperature > limits.MaxTemperature ||
+			s.humidity < limits.MinHumidity || s.humidity > limits.MaxHumidity
+		if !outOfRange {
+			continue
+		}
+		summary.Breached = true
+		if i+1 < len(samples) {
+			summary.SecondsOutOfRange += int64(samples[i+1].at.Sub(s.at) / time.Second)
+		}
+	}
+
+	return summary, nil
+}
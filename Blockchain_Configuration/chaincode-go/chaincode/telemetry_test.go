@@ -0,0 +1,94 @@
+package chaincode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestRecordTelemetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		batch       string
+		wantErr     string
+		wantBreach  bool
+		wantSeconds int64
+		wantEvent   string
+	}{
+		{
+			name:      "cold chain kept",
+			batch:     `{"deviceId": "logger-1", "readings": [{"timestamp": "2024-03-01T06:00:00Z", "temperature": 12, "humidity": 90}, {"timestamp": "2024-03-01T07:00:00Z", "temperature": 13, "humidity": 88}]}`,
+			wantEvent: EventTelemetryRecorded,
+		},
+		{
+			name:        "too warm for an hour",
+			batch:       `{"deviceId": "logger-1", "readings": [{"timestamp": "2024-03-01T06:00:00Z", "temperature": 12, "humidity": 90}, {"timestamp": "2024-03-01T07:00:00Z", "temperature": 21, "humidity": 90}, {"timestamp": "2024-03-01T08:00:00Z", "temperature": 12, "humidity": 90}]}`,
+			wantBreach:  true,
+			wantSeconds: 3600,
+			wantEvent:   EventColdChainBreach,
+		},
+		{
+			name:    "no readings",
+			batch:   `{"deviceId": "logger-1", "readings": []}`,
+			wantErr: "needs at least one reading",
+		},
+		{
+			name:    "reading without humidity",
+			batch:   `{"deviceId": "logger-1", "readings": [{"timestamp": "2024-03-01T06:00:00Z", "temperature": 12}]}`,
+			wantErr: "reading 0 needs a temperature and a humidity",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newTestLedger(t)
+			ledger.harvest("A", 100)
+
+			var summary *TelemetrySummary
+			err := ledger.submit(farmerClient, map[string][]byte{telemetryTransientKey: []byte(tt.batch)}, func(ctx contractapi.TransactionContextInterface) error {
+				var err error
+				summary, err = ledger.contract.RecordTelemetry(ctx, "A")
+				return err
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if summary.Breached != tt.wantBreach || summary.SecondsOutOfRange != tt.wantSeconds || summary.Limits != defaultTelemetryLimits {
+				t.Errorf("got breached %t for %ds against %+v", summary.Breached, summary.SecondsOutOfRange, summary.Limits)
+			}
+			if flagged := len(ledger.asset("A").QualityFlags) > 0; flagged != tt.wantBreach {
+				t.Errorf("got the lot flagged %t, want %t", flagged, tt.wantBreach)
+			}
+			if ledger.lastEvent() != tt.wantEvent {
+				t.Errorf("got event %q, want %q", ledger.lastEvent(), tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestTelemetryLimitsCannotBeChanged(t *testing.T) {
+	ledger := newTestLedger(t)
+
+	message := ledger.invoke(farmerClient, "SetTelemetryLimits", `{"variety": "Roma", "minTemperature": -50, "maxTemperature": 60, "minHumidity": 0, "maxHumidity": 100}`)
+	if !strings.Contains(message, "SetTelemetryLimits not found") {
+		t.Fatalf("got %q from a farmer changing the telemetry limits, want the call refused", message)
+	}
+
+	var limits *TelemetryLimits
+	ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		limits, err = ledger.contract.GetTelemetryLimits(ctx, "Roma")
+		return err
+	})
+	if *limits != defaultTelemetryLimits {
+		t.Errorf("got limits %+v for Roma, want %+v", *limits, defaultTelemetryLimits)
+	}
+}
@@ -0,0 +1,550 @@
+package chaincode
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key object types of transfer offers and the indexes that find them by asset and by party
+const (
+	offerObjectType = "offer"
+	offerAssetIndex = "asset~offer"
+	offerPartyIndex = "party~offer"
+)
+
+// How long a transfer offer stays open when the seller does not say, and the longest a seller may keep one open
+const (
+	defaultOfferExpiry = 72 * time.Hour
+	maxOfferExpiry     = 30 * 24 * time.Hour
+)
+
+// States of a transfer offer. An offer is only stored as Pending, Accepted or Rejected; a pending offer past
+// its expiry is reported as Expired.
+const (
+	OfferPending  = "Pending"
+	OfferAccepted = "Accepted"
+	OfferRejected = "Rejected"
+	OfferExpired  = "Expired"
+)
+
+// transferStage is a step of the supply chain a lot can be handed over at: the role of the buyer, the status
+// the lot moves to on acceptance and the kind of price agreed
+type transferStage struct {
+	buyerRole string
+	status    string
+	priceKind string
+}
+
+// transferStages maps the custody status a lot is handed over from to the stage it enters
+var transferStages = map[string]transferStage{
+	StatusHarvested:      {RoleWholesaler, StatusWithWholesaler, PriceWholesale},
+	StatusWithWholesaler: {RoleRetailer, StatusWithRetailer, PriceRetail},
+}
+
+// TransferOffer is a proposal by the holder of a lot to hand it over to a buyer. Custody only changes when
+// the buyer accepts. An offer of less than the whole lot names the LotID the transferred part is split
+// off under. The price, if any, is kept in the private data collection of the two parties.
+type TransferOffer struct {
+	ID        string    `json:"ID"`
+	AssetID   string    `json:"AssetID"`
+	LotID     string    `json:"LotID"`
+	Stage     string    `json:"Stage"`
+	SellerId  string    `json:"SellerId"`
+	SellerMSP string    `json:"SellerMSP"`
+	BuyerId   string    `json:"BuyerId"`
+	BuyerMSP  string    `json:"BuyerMSP"`
+	Quantity  float64   `json:"Quantity"`
+	Unit      string    `json:"Unit"`
+	PriceHash string    `json:"PriceHash"`
+	Status    string    `json:"Status"`
+	Reason    string    `json:"Reason"`
+	CreatedAt time.Time `json:"CreatedAt"`
+	ExpiresAt time.Time `json:"ExpiresAt"`
+}
+
+// OfferRequest is the payload the holder of a lot submits to offer it. lotId is required when quantity is
+// less than the lot holds. expiresIn is how long the offer stays open, as a duration such as "48h", from a
+// second up to 30 days; it defaults to 72 hours. The price, if any, is passed in the transient map under "price".
+type OfferRequest struct {
+	AssetID   string  `json:"assetId"`
+	BuyerID   string  `json:"buyerId"`
+	Quantity  float64 `json:"quantity"`
+	LotID     string  `json:"lotId"`
+	ExpiresIn string  `json:"expiresIn"`
+}
+
+// OfferTransfer offers a lot, or part of it, to a registered buyer at the next stage of the supply chain:
+// a farmer offers to a wholesaler and a wholesaler to a retailer. Only the organization holding the lot may
+// offer it, and a lot can have one open offer at a time and none while it is on an open shipment. The offer ID
+// is returned.
+func (s *SmartContract) OfferTransfer(ctx contractapi.TransactionContextInterface, offerJSON string) (string, error) {
+	var request OfferRequest
+	err := decodePayload(offerJSON, &request)
+	if err != nil {
+		return "", err
+	}
+
+	asset, err := s.ReadAsset(ctx, request.AssetID)
+	if err != nil {
+		return "", err
+	}
+	stage, ok := transferStages[asset.Status]
+	if !ok {
+		return "", fmt.Errorf("the asset %s is %s and cannot be transferred", asset.ID, asset.Status)
+	}
+	sellerMSP := custodianMSP(asset.Status)
+	err = requireMSP(ctx, "offer asset "+asset.ID, sellerMSP)
+	if err != nil {
+		return "", err
+	}
+	seller, err := activeParticipant(ctx, sellerRole(asset.Status), asset.CurrentOwnerId)
+	if err != nil {
+		return "", err
+	}
+	buyer, err := activeParticipant(ctx, stage.buyerRole, request.BuyerID)
+	if err != nil {
+		return "", err
+	}
+	err = s.checkOfferQuantity(ctx, asset, request.Quantity, request.LotID)
+	if err != nil {
+		return "", err
+	}
+
+	err = checkNotCommitted(ctx, asset.ID)
+	if err != nil {
+		return "", err
+	}
+
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return "", fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+	expiry, err := offerExpiry(request.ExpiresIn)
+	if err != nil {
+		return "", err
+	}
+
+	offer := &TransferOffer{
+		ID:        ctx.GetStub().GetTxID(),
+		AssetID:   asset.ID,
+		LotID:     request.LotID,
+		Stage:     asset.Status,
+		SellerId:  seller.ID,
+		SellerMSP: sellerMSP,
+		BuyerId:   buyer.ID,
+		BuyerMSP:  buyer.MSPID,
+		Quantity:  request.Quantity,
+		Unit:      asset.Unit,
+		Status:    OfferPending,
+		CreatedAt: timestamp.AsTime(),
+		ExpiresAt: timestamp.AsTime().Add(expiry),
+	}
+	price, err := transientPrice(ctx)
+	if err != nil {
+		return "", err
+	}
+	if price != nil {
+		offer.PriceHash, err = putPrice(ctx, &PriceRecord{
+			ID:       offer.ID,
+			Kind:     stage.priceKind,
+			Price:    *price.Price,
+			Currency: price.Currency,
+			Unit:     asset.Unit,
+			Salt:     hex.EncodeToString(price.salt),
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = putOffer(ctx, offer)
+	if err != nil {
+		return "", err
+	}
+	for _, key := range []struct{ index, value string }{
+		{offerAssetIndex, offer.AssetID},
+		{offerPartyIndex, offer.SellerId},
+		{offerPartyIndex, offer.BuyerId},
+	} {
+		indexKey, err := ctx.GetStub().CreateCompositeKey(key.index, []string{key.value, offer.ID})
+		if err != nil {
+			return "", err
+		}
+		err = ctx.GetStub().PutState(indexKey, indexValue)
+		if err != nil {
+			return "", fmt.Errorf("failed to put index entry for offer %s: %v", offer.ID, err)
+		}
+	}
+
+	err = setEvent(ctx, &AssetEvent{Type: EventTransferOffered, AssetID: asset.ID, ChangedFields: offerFields(offer)})
+	if err != nil {
+		return "", err
+	}
+
+	return offer.ID, nil
+}
+
+// AcceptTransfer accepts an open offer made to the client's organization. The lot, or the part of it that was
+// offered, moves into the buyer's custody and the agreed price becomes its purchase price.
+func (s *SmartContract) AcceptTransfer(ctx contractapi.TransactionContextInterface, offerID string) error {
+	offer, err := s.pendingOffer(ctx, offerID)
+	if err != nil {
+		return err
+	}
+	buyer, err := activeParticipant(ctx, transferStages[offer.Stage].buyerRole, offer.BuyerId)
+	if err != nil {
+		return err
+	}
+
+	asset, err := s.ReadAsset(ctx, offer.AssetID)
+	if err != nil {
+		return err
+	}
+	if asset.Status != offer.Stage || asset.CurrentOwnerId != offer.SellerId {
+		return fmt.Errorf("the asset %s has changed hands since offer %s was made", asset.ID, offer.ID)
+	}
+	err = s.checkOfferQuantity(ctx, asset, offer.Quantity, offer.LotID)
+	if err != nil {
+		return err
+	}
+	stage := transferStages[offer.Stage]
+	err = checkTransition(asset.ID, asset.Status, stage.status)
+	if err != nil {
+		return err
+	}
+
+	buyDate, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	lot := asset
+	if offer.LotID != "" {
+		subLot := *asset
+		subLot.ID = offer.LotID
+		subLot.Quantity = offer.Quantity
+		subLot.RemainingQuantity = offer.Quantity
+		subLot.ParentID = asset.ID
+		subLot.SourceIDs = nil
+		subLot.ChildIDs = nil
+		subLot.PriceHash = ""
+		subLot.WholesalePriceHash = ""
+		subLot.RetailPriceHash = ""
+		lot = &subLot
+
+		asset.RemainingQuantity -= offer.Quantity
+		asset.ChildIDs = append(asset.ChildIDs, subLot.ID)
+	}
+
+	handOver(lot, stage, buyer, buyDate)
+
+	price, err := readPrice(ctx, priceCollections[stage.priceKind], offer.ID, stage.priceKind)
+	if err != nil {
+		return err
+	}
+	if price != nil {
+		price.ID = lot.ID
+		hash, err := putPrice(ctx, price)
+		if err != nil {
+			return err
+		}
+		if stage.priceKind == PriceWholesale {
+			lot.WholesalePriceHash = hash
+		} else {
+			lot.RetailPriceHash = hash
+		}
+	}
+
+	offer.Status = OfferAccepted
+	err = putOffer(ctx, offer)
+	if err != nil {
+		return err
+	}
+
+	if lot != asset {
+		err = emitAssetEvent(ctx, EventTransferAccepted, lot, asset.ID)
+		if err != nil {
+			return err
+		}
+		err = putAsset(ctx, asset)
+		if err != nil {
+			return err
+		}
+		return putAsset(ctx, lot)
+	}
+
+	err = emitAssetEvent(ctx, EventTransferAccepted, lot)
+	if err != nil {
+		return err
+	}
+
+	return putAsset(ctx, lot)
+}
+
+// RejectTransfer turns down an open offer made to the client's organization, with an optional reason
+func (s *SmartContract) RejectTransfer(ctx contractapi.TransactionContextInterface, offerID, reason string) error {
+	offer, err := s.pendingOffer(ctx, offerID)
+	if err != nil {
+		return err
+	}
+
+	offer.Status = OfferRejected
+	offer.Reason = reason
+	err = putOffer(ctx, offer)
+	if err != nil {
+		return err
+	}
+
+	return setEvent(ctx, &AssetEvent{Type: EventTransferRejected, AssetID: offer.AssetID, ChangedFields: offerFields(offer)})
+}
+
+// GetTransferOffer returns an offer, reporting a pending offer past its expiry as Expired
+func (s *SmartContract) GetTransferOffer(ctx contractapi.TransactionContextInterface, offerID string) (*TransferOffer, error) {
+	offer, err := readOffer(ctx, offerID)
+	if err != nil {
+		return nil, err
+	}
+	if offer == nil {
+		return nil, fmt.Errorf("the offer %s does not exist", offerID)
+	}
+	err = markExpired(ctx, offer)
+	if err != nil {
+		return nil, err
+	}
+
+	return offer, nil
+}
+
+// GetTransferOffers returns the offers a participant made or received, newest first
+func (s *SmartContract) GetTransferOffers(ctx contractapi.TransactionContextInterface, participantID string) ([]*TransferOffer, error) {
+	return offersByIndex(ctx, offerPartyIndex, participantID)
+}
+
+// GetAssetTransferOffers returns the offers made for an asset, newest first
+func (s *SmartContract) GetAssetTransferOffers(ctx contractapi.TransactionContextInterface, id string) ([]*TransferOffer, error) {
+	return offersByIndex(ctx, offerAssetIndex, id)
+}
+
+// pendingOffer returns an offer the client's organization may answer, or an error if it is not open
+func (s *SmartContract) pendingOffer(ctx contractapi.TransactionContextInterface, offerID string) (*TransferOffer, error) {
+	offer, err := s.GetTransferOffer(ctx, offerID)
+	if err != nil {
+		return nil, err
+	}
+	err = requireMSP(ctx, "answer offer "+offer.ID, offer.BuyerMSP)
+	if err != nil {
+		return nil, err
+	}
+	if offer.Status != OfferPending {
+		return nil, fmt.Errorf("the offer %s is %s", offer.ID, offer.Status)
+	}
+
+	return offer, nil
+}
+
+// checkOfferQuantity checks that a lot holds the quantity offered and, for part of a lot, that the ID the
+// part will be split off under is free
+func (s *SmartContract) checkOfferQuantity(ctx contractapi.TransactionContextInterface, asset *Asset, quantity float64, lotID string) error {
+	if quantity <= 0 {
+		return fmt.Errorf("invalid payload: quantity must be greater than zero")
+	}
+	if quantity > asset.RemainingQuantity+quantityTolerance {
+		return fmt.Errorf("the asset %s has %g %s remaining, less than the %g %s offered",
+			asset.ID, asset.RemainingQuantity, asset.Unit, quantity, asset.Unit)
+	}
+
+	whole := math.Abs(quantity-asset.RemainingQuantity) <= quantityTolerance
+	if whole && lotID != "" {
+		return fmt.Errorf("invalid payload: lotId is only used when offering part of a lot")
+	}
+	if !whole {
+		if lotID == "" {
+			return fmt.Errorf("invalid payload: lotId is required to offer part of asset %s", asset.ID)
+		}
+		exists, err := s.AssetExists(ctx, lotID)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("the asset %s already exists", lotID)
+		}
+	}
+
+	return nil
+}
+
+// openOffer returns the pending, unexpired offer for an asset, if there is one
+func openOffer(ctx contractapi.TransactionContextInterface, assetID string) (*TransferOffer, error) {
+	offers, err := offersByIndex(ctx, offerAssetIndex, assetID)
+	if err != nil {
+		return nil, err
+	}
+	for _, offer := range offers {
+		if offer.Status == OfferPending {
+			return offer, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// offersByIndex returns the offers listed under an index value, newest first
+func offersByIndex(ctx contractapi.TransactionContextInterface, index, value string) ([]*TransferOffer, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(index, []string{value})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	offers := []*TransferOffer{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(attributes) != 2 {
+			return nil, fmt.Errorf("malformed %s index key %q", index, queryResponse.Key)
+		}
+
+		offer, err := readOffer(ctx, attributes[1])
+		if err != nil {
+			return nil, err
+		}
+		if offer == nil {
+			continue
+		}
+		err = markExpired(ctx, offer)
+		if err != nil {
+			return nil, err
+		}
+		offers = append(offers, offer)
+	}
+	sort.SliceStable(offers, func(i, j int) bool {
+		return offers[i].CreatedAt.After(offers[j].CreatedAt)
+	})
+
+	return offers, nil
+}
+
+// markExpired reports a pending offer as Expired once the transaction time is past its expiry
+func markExpired(ctx contractapi.TransactionContextInterface, offer *TransferOffer) error {
+	if offer.Status != OfferPending {
+		return nil
+	}
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to read transaction timestamp: %v", err)
+	}
+	if timestamp.AsTime().After(offer.ExpiresAt) {
+		offer.Status = OfferExpired
+	}
+
+	return nil
+}
+
+func readOffer(ctx contractapi.TransactionContextInterface, offerID string) (*TransferOffer, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(offerObjectType, []string{offerID})
+	if err != nil {
+		return nil, err
+	}
+	offerJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read offer %s from world state: %v", offerID, err)
+	}
+	if offerJSON == nil {
+		return nil, nil
+	}
+
+	var offer TransferOffer
+	err = json.Unmarshal(offerJSON, &offer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &offer, nil
+}
+
+func putOffer(ctx contractapi.TransactionContextInterface, offer *TransferOffer) error {
+	key, err := ctx.GetStub().CreateCompositeKey(offerObjectType, []string{offer.ID})
+	if err != nil {
+		return err
+	}
+	offerJSON, err := json.Marshal(offer)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(key, offerJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put offer %s: %v", offer.ID, err)
+	}
+
+	return nil
+}
+
+// offerExpiry parses how long the seller keeps an offer open, applying the default when none is given
+func offerExpiry(expiresIn string) (time.Duration, error) {
+	if expiresIn == "" {
+		return defaultOfferExpiry, nil
+	}
+	duration, err := time.ParseDuration(expiresIn)
+	if err != nil {
+		return 0, fmt.Errorf("invalid payload: expiresIn: %v", err)
+	}
+	if duration < time.Second || duration > maxOfferExpiry {
+		return 0, fmt.Errorf("invalid payload: expiresIn must be between 1s and 720h")
+	}
+
+	return duration, nil
+}
+
+// offerFields describes an offer in an event
+func offerFields(offer *TransferOffer) map[string]interface{} {
+	return map[string]interface{}{
+		"OfferID":  offer.ID,
+		"LotID":    offer.LotID,
+		"SellerId": offer.SellerId,
+		"BuyerId":  offer.BuyerId,
+		"Quantity": offer.Quantity,
+		"Unit":     offer.Unit,
+		"Status":   offer.Status,
+		"Reason":   offer.Reason,
+	}
+}
+
+// handOver moves a lot into the custody of a buyer at the next stage, bought on buyDate
+func handOver(lot *Asset, stage transferStage, buyer *Participant, buyDate string) {
+	lot.Status = stage.status
+	takeCustody(lot, buyer.ID, buyer.MSPID)
+	if stage.buyerRole == RoleWholesaler {
+		lot.WholesalerId = buyer.ID
+		lot.WholesalerName = buyer.Name
+		lot.WholesalerBuyDate = buyDate
+	} else {
+		lot.RetailerId = buyer.ID
+		lot.RetailerName = buyer.Name
+		lot.RetailerBuyDate = buyDate
+	}
+}
+
+// sellerRole returns the role of the participant holding a lot in a custody status
+func sellerRole(status string) string {
+	switch status {
+	case StatusWithRetailer:
+		return RoleRetailer
+	case StatusWithWholesaler:
+		return RoleWholesaler
+	default:
+		return RoleFarmer
+	}
+}
@@ -0,0 +1,206 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func TestOfferTransfer(t *testing.T) {
+	shortSalt := priceTransient(t, 1200)
+	shortSalt[saltTransientKey] = shortSalt[saltTransientKey][:16]
+	noSalt := priceTransient(t, 1200)
+	delete(noSalt, saltTransientKey)
+
+	tests := []struct {
+		name      string
+		client    testIdentity
+		offer     string
+		transient map[string][]byte
+		wantErr   string
+		wantHash  bool
+		wantTTL   time.Duration
+	}{
+		{name: "whole lot", client: farmerClient, offer: `{"assetId": "A", "buyerId": "wholesaler-1", "quantity": 100}`, wantTTL: defaultOfferExpiry},
+		{name: "part of a lot", client: farmerClient, offer: `{"assetId": "A", "buyerId": "wholesaler-1", "quantity": 40, "lotId": "A-1"}`, wantTTL: defaultOfferExpiry},
+		{name: "seller sets the expiry", client: farmerClient, offer: `{"assetId": "A", "buyerId": "wholesaler-1", "quantity": 100, "expiresIn": "2h"}`, wantTTL: 2 * time.Hour},
+		{name: "salted price", client: farmerClient, offer: `{"assetId": "A", "buyerId": "wholesaler-1", "quantity": 100}`, transient: priceTransient(t, 1200), wantHash: true, wantTTL: defaultOfferExpiry},
+		{name: "price without a salt", client: farmerClient, offer: `{"assetId": "A", "buyerId": "wholesaler-1", "quantity": 100}`, transient: noSalt, wantErr: "requires a random salt"},
+		{name: "price with a short salt", client: farmerClient, offer: `{"assetId": "A", "buyerId": "wholesaler-1", "quantity": 100}`, transient: shortSalt, wantErr: "has 16 bytes, at least 32"},
+		{name: "part of a lot without a lot ID", client: farmerClient, offer: `{"assetId": "A", "buyerId": "wholesaler-1", "quantity": 40}`, wantErr: "lotId is required"},
+		{name: "more than the lot holds", client: farmerClient, offer: `{"assetId": "A", "buyerId": "wholesaler-1", "quantity": 120}`, wantErr: "less than the 120 kg offered"},
+		{name: "buyer at the wrong stage", client: farmerClient, offer: `{"assetId": "A", "buyerId": "retailer-1", "quantity": 100}`, wantErr: "retailer-1"},
+		{name: "organization not holding the lot", client: wholesalerClient, offer: `{"assetId": "A", "buyerId": "wholesaler-1", "quantity": 100}`, wantErr: "not authorized to offer asset A"},
+		{name: "expiry too short", client: farmerClient, offer: `{"assetId": "A", "buyerId": "wholesaler-1", "quantity": 100, "expiresIn": "500ms"}`, wantErr: "expiresIn must be between"},
+		{name: "expiry too long", client: farmerClient, offer: `{"assetId": "A", "buyerId": "wholesaler-1", "quantity": 100, "expiresIn": "800h"}`, wantErr: "expiresIn must be between"},
+		{name: "unreadable expiry", client: farmerClient, offer: `{"assetId": "A", "buyerId": "wholesaler-1", "quantity": 100, "expiresIn": "soon"}`, wantErr: "invalid payload: expiresIn"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newTestLedger(t)
+			ledger.harvest("A", 100)
+
+			var offerID string
+			err := ledger.submit(tt.client, tt.transient, func(ctx contractapi.TransactionContextInterface) error {
+				var err error
+				offerID, err = ledger.contract.OfferTransfer(ctx, tt.offer)
+				return err
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			offer := readTestOffer(ledger, offerID)
+			if offer.Status != OfferPending || offer.SellerId != testFarmer || offer.BuyerId != testWholesaler {
+				t.Errorf("got offer %s from %q to %q", offer.Status, offer.SellerId, offer.BuyerId)
+			}
+			if ttl := offer.ExpiresAt.Sub(offer.CreatedAt); ttl != tt.wantTTL {
+				t.Errorf("got an offer open for %s, want %s", ttl, tt.wantTTL)
+			}
+			if (offer.PriceHash != "") != tt.wantHash {
+				t.Errorf("got price hash %q", offer.PriceHash)
+			}
+			if ledger.lastEvent() != EventTransferOffered {
+				t.Errorf("got event %q, want %q", ledger.lastEvent(), EventTransferOffered)
+			}
+		})
+	}
+}
+
+func TestAnswerTransfer(t *testing.T) {
+	tests := []struct {
+		name       string
+		offer      string
+		elapsed    time.Duration
+		client     testIdentity
+		reject     bool
+		wantErr    string
+		wantOffer  string
+		wantStatus string
+		wantLot    string
+	}{
+		{name: "buyer accepts the whole lot", offer: `{"assetId": "A", "buyerId": "wholesaler-1", "quantity": 100}`, client: wholesalerClient, wantOffer: OfferAccepted, wantStatus: StatusWithWholesaler, wantLot: "A"},
+		{name: "buyer accepts part of the lot", offer: `{"assetId": "A", "buyerId": "wholesaler-1", "quantity": 40, "lotId": "A-1"}`, client: wholesalerClient, wantOffer: OfferAccepted, wantStatus: StatusHarvested, wantLot: "A-1"},
+		{name: "buyer rejects", offer: `{"assetId": "A", "buyerId": "wholesaler-1", "quantity": 100}`, client: wholesalerClient, reject: true, wantOffer: OfferRejected, wantStatus: StatusHarvested},
+		{name: "seller cannot accept its own offer", offer: `{"assetId": "A", "buyerId": "wholesaler-1", "quantity": 100}`, client: farmerClient, wantErr: "not authorized to answer offer", wantOffer: OfferPending, wantStatus: StatusHarvested},
+		{name: "expired offer", offer: `{"assetId": "A", "buyerId": "wholesaler-1", "quantity": 100, "expiresIn": "1h"}`, elapsed: 2 * time.Hour, client: wholesalerClient, wantErr: "is Expired", wantOffer: OfferExpired, wantStatus: StatusHarvested},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := newTestLedger(t)
+			ledger.harvest("A", 100)
+			var offerID string
+			ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+				var err error
+				offerID, err = ledger.contract.OfferTransfer(ctx, tt.offer)
+				return err
+			})
+			ledger.elapsed = tt.elapsed
+
+			err := ledger.submit(tt.client, nil, func(ctx contractapi.TransactionContextInterface) error {
+				if tt.reject {
+					return ledger.contract.RejectTransfer(ctx, offerID, "too ripe")
+				}
+				return ledger.contract.AcceptTransfer(ctx, offerID)
+			})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+
+			if offer := readTestOffer(ledger, offerID); offer.Status != tt.wantOffer {
+				t.Errorf("got offer %s, want %s", offer.Status, tt.wantOffer)
+			}
+			asset := ledger.asset("A")
+			if asset.Status != tt.wantStatus {
+				t.Errorf("got asset status %s, want %s", asset.Status, tt.wantStatus)
+			}
+			if tt.wantLot == "" {
+				return
+			}
+			lot := ledger.asset(tt.wantLot)
+			if lot.Status != StatusWithWholesaler || lot.CurrentOwnerId != testWholesaler || lot.WholesalerId != testWholesaler || lot.WholesalerBuyDate == "" {
+				t.Errorf("got lot %s %s held by %q, bought by %q on %q", lot.ID, lot.Status, lot.CurrentOwnerId, lot.WholesalerId, lot.WholesalerBuyDate)
+			}
+			if lot.ID != asset.ID {
+				if lot.ParentID != "A" || lot.RemainingQuantity != 40 || asset.RemainingQuantity != 60 || asset.CurrentOwnerId != testFarmer {
+					t.Errorf("got sub-lot of %g from %q, leaving %g with %q", lot.RemainingQuantity, lot.ParentID, asset.RemainingQuantity, asset.CurrentOwnerId)
+				}
+			}
+		})
+	}
+}
+
+func TestTransferPriceHashIsSalted(t *testing.T) {
+	ledger := newTestLedger(t)
+	ledger.harvest("A", 100)
+	ledger.harvest("B", 100)
+
+	// The same price agreed for two lots must not give the same public hash
+	hashes := make(map[string]string)
+	for _, id := range []string{"A", "B"} {
+		var offerID string
+		err := ledger.submit(farmerClient, priceTransient(t, 1200), func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			offerID, err = ledger.contract.OfferTransfer(ctx, fmt.Sprintf(`{"assetId": %q, "buyerId": "wholesaler-1", "quantity": 100}`, id))
+			return err
+		})
+		if err != nil {
+			t.Fatalf("failed to offer %s: %v", id, err)
+		}
+		ledger.mustSubmit(wholesalerClient, func(ctx contractapi.TransactionContextInterface) error {
+			return ledger.contract.AcceptTransfer(ctx, offerID)
+		})
+
+		lot := ledger.asset(id)
+		key, _ := ledger.stub.CreateCompositeKey(priceObjectType, []string{id, PriceWholesale})
+		priceJSON, err := ledger.stub.GetPrivateData(FarmerWholesalerCollection, key)
+		if err != nil || priceJSON == nil {
+			t.Fatalf("no wholesale price stored for %s: %v", id, err)
+		}
+		var price PriceRecord
+		if err := json.Unmarshal(priceJSON, &price); err != nil {
+			t.Fatal(err)
+		}
+		if salt, _ := hex.DecodeString(price.Salt); len(salt) < minSaltLength {
+			t.Errorf("the price of %s is stored with salt %q", id, price.Salt)
+		}
+		sum := sha256.Sum256(priceJSON)
+		if lot.WholesalePriceHash != hex.EncodeToString(sum[:]) {
+			t.Errorf("the public hash of %s does not match its private record", id)
+		}
+		hashes[id] = lot.WholesalePriceHash
+	}
+
+	if hashes["A"] == hashes["B"] {
+		t.Errorf("two lots with the same price have the same hash %s", hashes["A"])
+	}
+}
+
+// readTestOffer reads a transfer offer, failing the test if it cannot
+func readTestOffer(ledger *testLedger, offerID string) *TransferOffer {
+	ledger.t.Helper()
+	var offer *TransferOffer
+	ledger.mustSubmit(farmerClient, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		offer, err = ledger.contract.GetTransferOffer(ctx, offerID)
+		return err
+	})
+	return offer
+}
@@ -0,0 +1,157 @@
+package chaincode
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Units a lot quantity may be measured in
+const (
+	UnitKg    = "kg"
+	UnitCrate = "crate"
+	UnitTon   = "ton"
+)
+
+// unitAliases maps the spellings found in free-form quantities to the supported units
+var unitAliases = map[string]string{
+	"kg":        UnitKg,
+	"kgs":       UnitKg,
+	"kilo":      UnitKg,
+	"kilos":     UnitKg,
+	"kilogram":  UnitKg,
+	"kilograms": UnitKg,
+	"crate":     UnitCrate,
+	"crates":    UnitCrate,
+	"t":         UnitTon,
+	"ton":       UnitTon,
+	"tons":      UnitTon,
+	"tonne":     UnitTon,
+	"tonnes":    UnitTon,
+}
+
+// currencyPattern matches ISO 4217 alphabetic currency codes
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// legacyPricePattern matches free-form prices such as "100", "12.50", "GHS 12.50" or "12.50 GHS"
+var legacyPricePattern = regexp.MustCompile(`^([A-Za-z]{3})?\s*([0-9]+)(?:\.([0-9]{1,2}))?\s*([A-Za-z]{3})?$`)
+
+// legacyQuantityPattern matches free-form quantities such as "100", "100kg" or "3 crates"
+var legacyQuantityPattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([A-Za-z]+)?$`)
+
+// legacyDateLayouts are the date formats accepted when converting records written before dates were RFC3339
+var legacyDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006/01/02",
+}
+
+// normalizeUnit returns the supported unit a spelling refers to
+func normalizeUnit(unit string) (string, error) {
+	normalized, ok := unitAliases[strings.ToLower(strings.TrimSpace(unit))]
+	if !ok {
+		return "", fmt.Errorf("unsupported unit %q; use %s, %s or %s", unit, UnitKg, UnitCrate, UnitTon)
+	}
+
+	return normalized, nil
+}
+
+// kilogramsPerUnit converts the units with a fixed weight to kilograms; crates vary and are not converted
+var kilogramsPerUnit = map[string]float64{
+	UnitKg:  1,
+	UnitTon: 1000,
+}
+
+// kilograms converts a quantity to kilograms, reporting false for units without a fixed weight
+func kilograms(quantity float64, unit string) (float64, bool) {
+	factor, ok := kilogramsPerUnit[unit]
+	if !ok {
+		return 0, false
+	}
+
+	return quantity * factor, true
+}
+
+// validateCurrency checks that a currency is an ISO 4217 code such as GHS
+func validateCurrency(currency string) error {
+	if !currencyPattern.MatchString(currency) {
+		return fmt.Errorf("invalid currency %q; use a three letter ISO 4217 code such as GHS", currency)
+	}
+
+	return nil
+}
+
+// normalizeDate checks that a date is RFC3339 and returns it in UTC, so stored dates sort in time order
+func normalizeDate(date string) (string, error) {
+	parsed, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return "", fmt.Errorf("invalid date %q; use RFC3339, for example 2024-01-31T08:00:00Z", date)
+	}
+
+	return parsed.UTC().Format(time.RFC3339), nil
+}
+
+// parseLegacyDate converts a free-form date written by an earlier version of the chaincode to RFC3339
+func parseLegacyDate(date string) (string, error) {
+	date = strings.TrimSpace(date)
+	for _, layout := range legacyDateLayouts {
+		if parsed, err := time.Parse(layout, date); err == nil {
+			return parsed.UTC().Format(time.RFC3339), nil
+		}
+	}
+
+	return "", fmt.Errorf("cannot parse date %q", date)
+}
+
+// parseLegacyPrice converts a free-form price to minor units, assuming two decimal places.
+// The currency is returned when the price names one.
+func parseLegacyPrice(price string) (int64, string, error) {
+	match := legacyPricePattern.FindStringSubmatch(strings.ReplaceAll(strings.TrimSpace(price), ",", ""))
+	if match == nil || (match[1] != "" && match[4] != "") {
+		return 0, "", fmt.Errorf("cannot parse price %q", price)
+	}
+
+	major, err := strconv.ParseInt(match[2], 10, 64)
+	if err != nil || major > math.MaxInt64/100 {
+		return 0, "", fmt.Errorf("cannot parse price %q", price)
+	}
+	minor := int64(0)
+	if match[3] != "" {
+		fraction := match[3]
+		if len(fraction) == 1 {
+			fraction += "0"
+		}
+		minor, _ = strconv.ParseInt(fraction, 10, 64)
+	}
+
+	currency := strings.ToUpper(match[1] + match[4])
+	return major*100 + minor, currency, nil
+}
+
+// parseLegacyQuantity converts a free-form quantity to a number and, when the quantity names one, a unit
+func parseLegacyQuantity(quantity string) (float64, string, error) {
+	match := legacyQuantityPattern.FindStringSubmatch(strings.ReplaceAll(strings.TrimSpace(quantity), ",", ""))
+	if match == nil {
+		return 0, "", fmt.Errorf("cannot parse quantity %q", quantity)
+	}
+
+	amount, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("cannot parse quantity %q", quantity)
+	}
+	if match[2] == "" {
+		return amount, "", nil
+	}
+
+	unit, err := normalizeUnit(match[2])
+	if err != nil {
+		return 0, "", fmt.Errorf("cannot parse quantity %q: %v", quantity, err)
+	}
+
+	return amount, unit, nil
+}
@@ -3,6 +3,7 @@ package web
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 )
 
@@ -11,28 +12,52 @@ func (setup *OrgSetup) CreateAsset(w http.ResponseWriter, r *http.Request) {
 
 	// Define a structure for the expected JSON payload
 	type Request struct {
-		ID           string `json:"id"`
-		FarmerId     string `json:"farmerId"`
-		FarmerName   string `json:"farmerName"`
-		FarmLocation string `json:"farmLocation"`
-		Variety      string `json:"variety"`
-		BatchNo      string `json:"batchNo"`
-		HarvestDate  string `json:"harvestDate"`
-		Price        string `json:"price"`
-		Quantity     string `json:"quantity"`
+		ID           string   `json:"id"`
+		FarmerId     string   `json:"farmerId"`
+		FarmerName   string   `json:"farmerName"`
+		FarmLocation string   `json:"farmLocation"`
+		Variety      string   `json:"variety"`
+		BatchNo      string   `json:"batchNo"`
+		HarvestDate  string   `json:"harvestDate"`
+		Quantity     *float64 `json:"quantity"`
+		Unit         string   `json:"unit"`
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body: "+err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	var requestData Request
-	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+	if err := json.Unmarshal(body, &requestData); err != nil {
+		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The price, if any, travels in the transient map rather than the public payload
+	transient, err := priceTransient(body)
+	if err != nil {
 		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	if requestData.ID == "" {
+		http.Error(w, "Field 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	payload, err := json.Marshal(requestData)
+	if err != nil {
+		http.Error(w, "JSON Marshal error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	network := setup.Gateway.GetNetwork(setup.Channel)
 	contract := network.GetContract(setup.Chaincode)
 
 	// Submit transaction to the ledger to create the asset
-	_, err := contract.SubmitTransaction("CreateAsset", requestData.ID, requestData.FarmerId, requestData.FarmLocation, requestData.Variety, requestData.BatchNo, requestData.HarvestDate, requestData.Price, requestData.Quantity, "", "", "", "", "")
+	_, err = contract.Submit("CreateAsset", priceOptions(string(payload), transient, "Org1MSP", "Org2MSP")...)
 	if err != nil {
 		http.Error(w, "Error invoking CreateAsset: "+err.Error(), http.StatusInternalServerError)
 		return
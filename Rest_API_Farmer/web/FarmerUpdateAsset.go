@@ -3,6 +3,7 @@ package web
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 )
 
@@ -11,76 +12,54 @@ func (setup *OrgSetup) FarmerUpdateAsset(w http.ResponseWriter, r *http.Request)
 
 	// Define a structure for the expected JSON payload
 	type Request struct {
-		ID           string `json:"id"`
-		FarmerId     string `json:"farmerId"`
-		FarmerName   string `json:"farmerName"`
-		FarmLocation string `json:"farmLocation"`
-		Variety      string `json:"variety"`
-		BatchNo      string `json:"batchNo"`
-		HarvestDate  string `json:"harvestDate"`
-		Price        string `json:"price"`
-		Quantity     string `json:"quantity"`
+		ID           string   `json:"id"`
+		FarmerId     string   `json:"farmerId"`
+		FarmerName   string   `json:"farmerName"`
+		FarmLocation string   `json:"farmLocation"`
+		Variety      string   `json:"variety"`
+		BatchNo      string   `json:"batchNo"`
+		HarvestDate  string   `json:"harvestDate"`
+		Quantity     *float64 `json:"quantity"`
+		Unit         string   `json:"unit"`
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body: "+err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	var requestData Request
-	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+	if err := json.Unmarshal(body, &requestData); err != nil {
 		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	network := setup.Gateway.GetNetwork(setup.Channel)
-	contract := network.GetContract(setup.Chaincode)
-
-	// Retrieve existing asset data
-	result, err := contract.EvaluateTransaction("ReadAsset", requestData.ID)
+	// The price, if any, travels in the transient map rather than the public payload
+	transient, err := priceTransient(body)
 	if err != nil {
-		http.Error(w, "Error invoking ReadAsset: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Unmarshal the asset data to update it
-	var asset map[string]string
-	if err := json.Unmarshal(result, &asset); err != nil {
-		http.Error(w, "JSON Unmarshal error: "+err.Error(), http.StatusInternalServerError)
+	if requestData.ID == "" {
+		http.Error(w, "Field 'id' is missing", http.StatusBadRequest)
 		return
 	}
 
-	// Update the asset with the new farmer information
-	if requestData.FarmerId != "" {
-
-		asset["FarmerId"] = requestData.FarmerId
-	}
-	if requestData.FarmerName != "" {
-		asset["FarmerName"] = requestData.FarmerName
-	}
-	if requestData.FarmLocation != "" {
-
-		asset["FarmLocation"] = requestData.FarmLocation
-	}
-	if requestData.Variety != "" {
-
-		asset["Variety"] = requestData.Variety
-	}
-	if requestData.BatchNo != "" {
-
-		asset["BatchNo"] = requestData.BatchNo
-	}
-	if requestData.HarvestDate != "" {
-		asset["HarvestDate"] = requestData.HarvestDate
-	}
-	if requestData.Price != "" {
-
-		asset["Price"] = requestData.Price
+	payload, err := json.Marshal(requestData)
+	if err != nil {
+		http.Error(w, "JSON Marshal error: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
-	if requestData.Quantity != "" {
 
-		asset["Quantity"] = requestData.Quantity
-	}
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
 
-	// Submit transaction to the ledger to update the asset
-	_, err = contract.SubmitTransaction("UpdateAsset", requestData.ID, asset["FarmerId"], asset["FarmLocation"], asset["Variety"], asset["BatchNo"], asset["HarvestDate"], asset["Price"], asset["Quantity"], asset["WholesalerId"], asset["WholesalerPrice"], asset["WholesalerBuyDate"], asset["RetailerId"], asset["RetailerBuyDate"])
+	// Submit transaction to the ledger to merge the new farmer information into the asset
+	_, err = contract.Submit("RecordHarvest", priceOptions(string(payload), transient, "Org1MSP", "Org2MSP")...)
 	if err != nil {
-		http.Error(w, "Error invoking UpdateAsset: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Error invoking RecordHarvest: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
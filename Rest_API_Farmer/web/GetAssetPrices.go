@@ -0,0 +1,87 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// GetAssetPrices returns the prices of an asset that this organization is party to. Prices are kept in
+// private data collections, so the query is answered by this organization's own peer.
+func (setup *OrgSetup) GetAssetPrices(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Get Asset Prices request")
+
+	// Extract 'id' from query parameters
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Query parameter 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Evaluate transaction using the ReadAssetPrices function from chaincode
+	result, err := contract.EvaluateTransaction("ReadAssetPrices", id)
+	if err != nil {
+		http.Error(w, "Error querying ReadAssetPrices: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var data []interface{}
+	if len(result) > 0 {
+		if err := json.Unmarshal(result, &data); err != nil {
+			http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if data == nil {
+		data = []interface{}{}
+	}
+
+	// Send the response with data
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+// priceTransient picks the optional price and currency out of a request body and packs them for the
+// transient map, so the price reaches the chaincode without being recorded in the transaction. A fresh
+// random salt is sent with every price so its public hash cannot be matched by trying likely prices.
+// It returns nil when the body has no price.
+func priceTransient(body []byte) (map[string][]byte, error) {
+	var price struct {
+		Price    *int64 `json:"price"`
+		Currency string `json:"currency"`
+	}
+	if err := json.Unmarshal(body, &price); err != nil {
+		return nil, err
+	}
+	if price.Price == nil {
+		return nil, nil
+	}
+
+	priceJSON, err := json.Marshal(price)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	return map[string][]byte{"price": priceJSON, "salt": salt}, nil
+}
+
+// priceOptions builds the proposal options for a transaction that may carry a price. A price is only
+// endorsed by the two organizations that share its private data collection.
+func priceOptions(payload string, transient map[string][]byte, partners ...string) []client.ProposalOption {
+	options := []client.ProposalOption{client.WithArguments(payload)}
+	if transient != nil {
+		options = append(options, client.WithTransient(transient), client.WithEndorsingOrganizations(partners...))
+	}
+
+	return options
+}
@@ -0,0 +1,61 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// assetLookups maps the query parameters accepted by /getBy to the chaincode index queries
+var assetLookups = []struct {
+	param       string
+	transaction string
+}{
+	{"farmerId", "GetAssetsByFarmer"},
+	{"wholesalerId", "GetAssetsByWholesaler"},
+	{"retailerId", "GetAssetsByRetailer"},
+	{"batchNo", "GetAssetsByBatch"},
+}
+
+func (setup *OrgSetup) GetAssetsBy(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Get Assets By request")
+
+	// Use the first lookup parameter present in the query
+	var transaction, value string
+	for _, lookup := range assetLookups {
+		if v := r.URL.Query().Get(lookup.param); v != "" {
+			transaction, value = lookup.transaction, v
+			break
+		}
+	}
+	if transaction == "" {
+		http.Error(w, "One of the query parameters 'farmerId', 'wholesalerId', 'retailerId' or 'batchNo' is required", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Evaluate the index query from chaincode
+	result, err := contract.EvaluateTransaction(transaction, value)
+	if err != nil {
+		http.Error(w, "Error querying "+transaction+": "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// No matching assets are returned as an empty list
+	var data []interface{}
+	if len(result) > 0 {
+		if err := json.Unmarshal(result, &data); err != nil {
+			http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if data == nil {
+		data = []interface{}{}
+	}
+
+	// Send the response with data
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,39 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// GetInventory lists the lots a participant holds with quantity remaining and its kilograms on hand by variety
+func (setup *OrgSetup) GetInventory(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Get Inventory request")
+
+	// Extract 'ownerId' from query parameters
+	ownerId := r.URL.Query().Get("ownerId")
+	if ownerId == "" {
+		http.Error(w, "Query parameter 'ownerId' is missing", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Evaluate transaction using the GetInventory function from chaincode
+	result, err := contract.EvaluateTransaction("GetInventory", ownerId)
+	if err != nil {
+		http.Error(w, "Error querying GetInventory: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(result, &data); err != nil {
+		http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with data
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,124 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// lineageGraph mirrors the graph returned by the chaincode lineage queries
+type lineageGraph struct {
+	RootID    string `json:"RootID"`
+	Direction string `json:"Direction"`
+	Nodes     []struct {
+		ID         string  `json:"ID"`
+		FarmerName string  `json:"FarmerName"`
+		Variety    string  `json:"Variety"`
+		Quantity   float64 `json:"Quantity"`
+		Unit       string  `json:"Unit"`
+		RetailerId string  `json:"RetailerId"`
+		Status     string  `json:"Status"`
+	} `json:"Nodes"`
+	Edges []struct {
+		From string `json:"From"`
+		To   string `json:"To"`
+		Type string `json:"Type"`
+	} `json:"Edges"`
+}
+
+func (setup *OrgSetup) GetLineage(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Get Lineage request")
+
+	// Extract 'id', 'direction' and 'format' from query parameters
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Query parameter 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	var transaction string
+	switch direction := r.URL.Query().Get("direction"); direction {
+	case "", "upstream":
+		transaction = "GetUpstreamLineage"
+	case "downstream":
+		transaction = "GetDownstreamLineage"
+	default:
+		http.Error(w, "Query parameter 'direction' must be 'upstream' or 'downstream'", http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "dot" {
+		http.Error(w, "Query parameter 'format' must be 'json' or 'dot'", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Evaluate the lineage query from chaincode
+	result, err := contract.EvaluateTransaction(transaction, id)
+	if err != nil {
+		http.Error(w, "Error querying "+transaction+": "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if format == "dot" {
+		var graph lineageGraph
+		if err := json.Unmarshal(result, &graph); err != nil {
+			http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		fmt.Fprint(w, lineageToDOT(&graph))
+		return
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(result, &data); err != nil {
+		http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with data
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+// lineageToDOT renders a lineage graph in the Graphviz DOT language, labelling each lot with its
+// farmer, variety, quantity and status and each edge with how the lots were divided or combined
+func lineageToDOT(graph *lineageGraph) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", strconv.Quote(graph.Direction+" lineage of "+graph.RootID))
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+
+	for _, node := range graph.Nodes {
+		label := []string{node.ID}
+		quantity := strings.TrimSpace(strconv.FormatFloat(node.Quantity, 'f', -1, 64) + " " + node.Unit)
+		for _, detail := range []string{node.Variety, node.FarmerName, quantity, node.Status} {
+			if detail != "" {
+				label = append(label, detail)
+			}
+		}
+		if node.RetailerId != "" {
+			label = append(label, "retailer "+node.RetailerId)
+		}
+
+		attributes := "label=" + strconv.Quote(strings.Join(label, "\n"))
+		if node.ID == graph.RootID {
+			attributes += ", style=bold"
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", strconv.Quote(node.ID), attributes)
+	}
+
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", strconv.Quote(edge.From), strconv.Quote(edge.To), strconv.Quote(edge.Type))
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,39 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// GetRecallImpact lists the lots derived from an asset and the retailers holding them, with quantities
+func (setup *OrgSetup) GetRecallImpact(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Get Recall Impact request")
+
+	// Extract 'id' from query parameters
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Query parameter 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Evaluate transaction using the GetRecallImpact function from chaincode
+	result, err := contract.EvaluateTransaction("GetRecallImpact", id)
+	if err != nil {
+		http.Error(w, "Error querying GetRecallImpact: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(result, &data); err != nil {
+		http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with data
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
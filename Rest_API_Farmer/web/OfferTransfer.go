@@ -0,0 +1,68 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// OfferTransfer offers a harvested lot, or part of it, to a wholesaler. The transfer only happens once the
+// wholesaler accepts the offer.
+func (setup *OrgSetup) OfferTransfer(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received OfferTransfer request")
+
+	// Define a structure for the expected JSON payload
+	type Request struct {
+		AssetID  string  `json:"assetId"`
+		BuyerID  string  `json:"buyerId"`
+		Quantity float64 `json:"quantity"`
+		LotID    string  `json:"lotId,omitempty"`
+		// ExpiresIn is how long the offer stays open, such as "48h"; the chaincode defaults to 72 hours
+		ExpiresIn string `json:"expiresIn,omitempty"`
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var requestData Request
+	if err := json.Unmarshal(body, &requestData); err != nil {
+		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The price, if any, travels in the transient map rather than the public payload
+	transient, err := priceTransient(body)
+	if err != nil {
+		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if requestData.AssetID == "" || requestData.BuyerID == "" {
+		http.Error(w, "Fields 'assetId' and 'buyerId' are required", http.StatusBadRequest)
+		return
+	}
+
+	payload, err := json.Marshal(requestData)
+	if err != nil {
+		http.Error(w, "JSON Marshal error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Submit transaction to the ledger to open the offer
+	offerID, err := contract.Submit("OfferTransfer", priceOptions(string(payload), transient, "Org1MSP", "Org2MSP")...)
+	if err != nil {
+		http.Error(w, "Error invoking OfferTransfer: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with the offer ID
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Transfer offered successfully", "offerId": string(offerID)})
+}
@@ -0,0 +1,122 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Participants manages the participant registry: GET ?id= reads a participant, POST registers one, PUT
+// updates one and DELETE ?id= deactivates one. Only participants in this organization's role may be
+// registered or changed.
+func (setup *OrgSetup) Participants(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Participants request")
+
+	switch r.Method {
+	case http.MethodGet:
+		setup.getParticipant(w, r)
+	case http.MethodPost:
+		setup.submitParticipant(w, r, "RegisterParticipant", "Participant registered successfully")
+	case http.MethodPut:
+		setup.submitParticipant(w, r, "UpdateParticipant", "Participant updated successfully")
+	case http.MethodDelete:
+		setup.deactivateParticipant(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (setup *OrgSetup) getParticipant(w http.ResponseWriter, r *http.Request) {
+	// Extract 'id' from query parameters
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Query parameter 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Evaluate transaction using the GetParticipant function from chaincode
+	result, err := contract.EvaluateTransaction("GetParticipant", id)
+	if err != nil {
+		http.Error(w, "Error querying GetParticipant: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(result, &data); err != nil {
+		http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with data
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+// submitParticipant passes a registration or update payload to the chaincode
+func (setup *OrgSetup) submitParticipant(w http.ResponseWriter, r *http.Request, function, message string) {
+	// Define a structure for the expected JSON payload
+	type Request struct {
+		ID       string `json:"id"`
+		Role     string `json:"role,omitempty"`
+		Name     string `json:"name,omitempty"`
+		Location string `json:"location,omitempty"`
+		Status   string `json:"status,omitempty"`
+	}
+
+	var requestData Request
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if requestData.ID == "" {
+		http.Error(w, "Field 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	payload, err := json.Marshal(requestData)
+	if err != nil {
+		http.Error(w, "JSON Marshal error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Submit transaction to the ledger to change the registry
+	_, err = contract.SubmitTransaction(function, string(payload))
+	if err != nil {
+		http.Error(w, "Error invoking "+function+": "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with the participant ID
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": message, "id": requestData.ID})
+}
+
+func (setup *OrgSetup) deactivateParticipant(w http.ResponseWriter, r *http.Request) {
+	// Extract 'id' from query parameters
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Query parameter 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Submit transaction to the ledger to deactivate the participant
+	_, err := contract.SubmitTransaction("DeactivateParticipant", id)
+	if err != nil {
+		http.Error(w, "Error invoking DeactivateParticipant: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with the participant ID
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Participant deactivated successfully", "id": id})
+}
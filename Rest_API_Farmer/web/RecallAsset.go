@@ -0,0 +1,47 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// RecallAsset recalls a lot and every lot derived from it
+func (setup *OrgSetup) RecallAsset(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received RecallAsset request")
+
+	// Define a structure for the expected JSON payload
+	type Request struct {
+		ID     string `json:"id"`
+		Reason string `json:"reason"`
+	}
+
+	var requestData Request
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if requestData.ID == "" {
+		http.Error(w, "Field 'id' is missing", http.StatusBadRequest)
+		return
+	}
+	if requestData.Reason == "" {
+		http.Error(w, "Field 'reason' is missing", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Submit transaction to the ledger to recall the asset and its derived lots
+	_, err := contract.SubmitTransaction("RecallAsset", requestData.ID, requestData.Reason)
+	if err != nil {
+		http.Error(w, "Error invoking RecallAsset: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with the recalled asset ID
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Asset recalled successfully", "id": requestData.ID})
+}
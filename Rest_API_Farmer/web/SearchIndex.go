@@ -0,0 +1,66 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Limits on the number of assets an index search returns
+const (
+	defaultSearchLimit = 100
+	maxSearchLimit     = 1000
+)
+
+// SearchIndex searches the off-chain asset index. Filters are given as the 'variety', 'status', 'farmerId',
+// 'wholesalerId', 'retailerId' and 'batchNo' query parameters, harvest date bounds as 'harvestFrom' and
+// 'harvestTo', free text as 'q', and paging as 'limit' and 'offset'.
+func (setup *OrgSetup) SearchIndex(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Search Index request")
+
+	if setup.Index == nil {
+		http.Error(w, "The asset index is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	search := &AssetSearch{
+		Variety:      query.Get("variety"),
+		Status:       query.Get("status"),
+		FarmerId:     query.Get("farmerId"),
+		WholesalerId: query.Get("wholesalerId"),
+		RetailerId:   query.Get("retailerId"),
+		BatchNo:      query.Get("batchNo"),
+		HarvestFrom:  query.Get("harvestFrom"),
+		HarvestTo:    query.Get("harvestTo"),
+		Text:         query.Get("q"),
+		Limit:        defaultSearchLimit,
+	}
+	if limit := query.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value <= 0 || value > maxSearchLimit {
+			http.Error(w, fmt.Sprintf("Query parameter 'limit' must be between 1 and %d", maxSearchLimit), http.StatusBadRequest)
+			return
+		}
+		search.Limit = value
+	}
+	if offset := query.Get("offset"); offset != "" {
+		value, err := strconv.Atoi(offset)
+		if err != nil || value < 0 {
+			http.Error(w, "Query parameter 'offset' must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		search.Offset = value
+	}
+
+	assets, err := setup.Index.Search(search)
+	if err != nil {
+		http.Error(w, "Error searching the asset index: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with data
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assets)
+}
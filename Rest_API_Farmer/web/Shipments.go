@@ -0,0 +1,183 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Shipments reads and plans shipments: GET ?id= reads a shipment, GET ?assetId= lists the shipments that
+// carried a lot and POST plans a shipment of lots this organization holds
+func (setup *OrgSetup) Shipments(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Shipments request")
+
+	switch r.Method {
+	case http.MethodGet:
+		setup.getShipments(w, r)
+	case http.MethodPost:
+		setup.createShipment(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// DispatchShipment records that a planned shipment has left
+func (setup *OrgSetup) DispatchShipment(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received DispatchShipment request")
+	setup.submitShipmentStep(w, r, "DispatchShipment", "Shipment dispatched successfully")
+}
+
+// CancelShipment calls off a planned or in-transit shipment sent by this organization, leaving the lots with it
+func (setup *OrgSetup) CancelShipment(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received CancelShipment request")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Define a structure for the expected JSON payload
+	type Request struct {
+		ID     string `json:"id"`
+		Reason string `json:"reason"`
+	}
+
+	var requestData Request
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if requestData.ID == "" {
+		http.Error(w, "Field 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Submit transaction to the ledger to cancel the shipment
+	_, err := contract.SubmitTransaction("CancelShipment", requestData.ID, requestData.Reason)
+	if err != nil {
+		http.Error(w, "Error invoking CancelShipment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with the shipment ID
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Shipment cancelled successfully", "id": requestData.ID})
+}
+
+func (setup *OrgSetup) getShipments(w http.ResponseWriter, r *http.Request) {
+	// Extract 'id' or 'assetId' from query parameters
+	function, arg := "GetShipment", r.URL.Query().Get("id")
+	if arg == "" {
+		function, arg = "GetAssetShipments", r.URL.Query().Get("assetId")
+	}
+	if arg == "" {
+		http.Error(w, "Query parameter 'id' or 'assetId' is missing", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Evaluate transaction using the chosen query function from chaincode
+	result, err := contract.EvaluateTransaction(function, arg)
+	if err != nil {
+		http.Error(w, "Error querying "+function+": "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(result, &data); err != nil {
+		http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with data
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+func (setup *OrgSetup) createShipment(w http.ResponseWriter, r *http.Request) {
+	// Define a structure for the expected JSON payload
+	type Request struct {
+		ID          string   `json:"id"`
+		Carrier     string   `json:"carrier"`
+		Vehicle     string   `json:"vehicle"`
+		Origin      string   `json:"origin"`
+		Destination string   `json:"destination"`
+		ReceiverID  string   `json:"receiverId"`
+		AssetIDs    []string `json:"assetIds"`
+	}
+
+	var requestData Request
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if requestData.ID == "" {
+		http.Error(w, "Field 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	payload, err := json.Marshal(requestData)
+	if err != nil {
+		http.Error(w, "JSON Marshal error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Submit transaction to the ledger to plan the shipment
+	_, err = contract.SubmitTransaction("CreateShipment", string(payload))
+	if err != nil {
+		http.Error(w, "Error invoking CreateShipment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with the shipment ID
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Shipment created successfully", "id": requestData.ID})
+}
+
+// submitShipmentStep moves the shipment named in the payload on to its next state
+func (setup *OrgSetup) submitShipmentStep(w http.ResponseWriter, r *http.Request, function, message string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Define a structure for the expected JSON payload
+	type Request struct {
+		ID string `json:"id"`
+	}
+
+	var requestData Request
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if requestData.ID == "" {
+		http.Error(w, "Field 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Submit transaction to the ledger to update the shipment
+	_, err := contract.SubmitTransaction(function, requestData.ID)
+	if err != nil {
+		http.Error(w, "Error invoking "+function+": "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with the shipment ID
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": message, "id": requestData.ID})
+}
@@ -0,0 +1,196 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// keepAliveInterval is how often an idle event stream sends a comment so proxies keep the connection open
+const keepAliveInterval = 15 * time.Second
+
+// streamedEvent is the data of each Server-Sent Event
+type streamedEvent struct {
+	BlockNumber   uint64          `json:"blockNumber"`
+	TransactionID string          `json:"transactionId"`
+	EventName     string          `json:"eventName"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// eventFilter selects the chaincode events a client asked for
+type eventFilter struct {
+	assetID string
+	types   map[string]bool
+}
+
+// StreamEvents streams chaincode events to the client as Server-Sent Events. The optional 'id' and 'type'
+// query parameters filter by asset ID and by a comma-separated list of event types. Each event carries an
+// SSE id of the form <block>:<transaction>; a client resumes after that event by sending it back as the
+// Last-Event-ID header or the 'checkpoint' parameter, or starts from a block with 'startBlock'.
+func (setup *OrgSetup) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Stream Events request")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	options, err := eventStartOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter := newEventFilter(r)
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+
+	// The subscription ends when the client disconnects and the request context is cancelled
+	events, err := network.ChaincodeEvents(r.Context(), setup.Chaincode, options...)
+	if err != nil {
+		http.Error(w, "Error subscribing to chaincode events: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if !filter.matches(event) {
+				continue
+			}
+			if err := writeEvent(w, event); err != nil {
+				fmt.Println("Error writing event: ", err)
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// eventStartOptions works out where a stream starts. A checkpoint, from the Last-Event-ID header or the
+// 'checkpoint' parameter, resumes after the event it names; otherwise 'startBlock' replays from that block
+// and with neither the stream starts at the next block.
+func eventStartOptions(r *http.Request) ([]client.ChaincodeEventsOption, error) {
+	checkpoint := r.Header.Get("Last-Event-ID")
+	if checkpoint == "" {
+		checkpoint = r.URL.Query().Get("checkpoint")
+	}
+	if checkpoint != "" {
+		blockNumber, transactionID, err := parseEventID(checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		checkpointer := new(client.InMemoryCheckpointer)
+		checkpointer.CheckpointTransaction(blockNumber, transactionID)
+		return []client.ChaincodeEventsOption{client.WithCheckpoint(checkpointer)}, nil
+	}
+
+	if startBlock := r.URL.Query().Get("startBlock"); startBlock != "" {
+		blockNumber, err := strconv.ParseUint(startBlock, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Query parameter 'startBlock' must be a block number")
+		}
+		return []client.ChaincodeEventsOption{client.WithStartBlock(blockNumber)}, nil
+	}
+
+	return nil, nil
+}
+
+// eventID identifies an event by its block and transaction, the position a stream resumes after
+func eventID(event *client.ChaincodeEvent) string {
+	return strconv.FormatUint(event.BlockNumber, 10) + ":" + event.TransactionID
+}
+
+func parseEventID(id string) (uint64, string, error) {
+	block, transactionID, found := strings.Cut(id, ":")
+	blockNumber, err := strconv.ParseUint(block, 10, 64)
+	if !found || err != nil || transactionID == "" {
+		return 0, "", fmt.Errorf("Checkpoint %q must have the form <block>:<transaction>", id)
+	}
+
+	return blockNumber, transactionID, nil
+}
+
+func newEventFilter(r *http.Request) *eventFilter {
+	filter := &eventFilter{assetID: r.URL.Query().Get("id")}
+	if types := r.URL.Query().Get("type"); types != "" {
+		filter.types = make(map[string]bool)
+		for _, eventType := range strings.Split(types, ",") {
+			filter.types[strings.TrimSpace(eventType)] = true
+		}
+	}
+
+	return filter
+}
+
+// matches reports whether an event is of a requested type and concerns the requested asset, either as
+// the asset it changed or as one of the related assets it names
+func (filter *eventFilter) matches(event *client.ChaincodeEvent) bool {
+	if filter.types != nil && !filter.types[event.EventName] {
+		return false
+	}
+	if filter.assetID == "" {
+		return true
+	}
+
+	var payload struct {
+		AssetID    string   `json:"AssetID"`
+		RelatedIDs []string `json:"RelatedIDs"`
+	}
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		return false
+	}
+	if payload.AssetID == filter.assetID {
+		return true
+	}
+	for _, id := range payload.RelatedIDs {
+		if id == filter.assetID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeEvent writes a chaincode event in the Server-Sent Events wire format
+func writeEvent(w http.ResponseWriter, event *client.ChaincodeEvent) error {
+	payload := json.RawMessage(event.Payload)
+	if !json.Valid(payload) {
+		payload, _ = json.Marshal(string(event.Payload))
+	}
+
+	data, err := json.Marshal(streamedEvent{
+		BlockNumber:   event.BlockNumber,
+		TransactionID: event.TransactionID,
+		EventName:     event.EventName,
+		Payload:       payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", eventID(event), event.EventName, data)
+	return err
+}
@@ -0,0 +1,79 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// GetTransferOffers lists transfer offers, newest first: ?participantId= returns the offers a participant
+// made or received and ?assetId= the offers made for a lot
+func (setup *OrgSetup) GetTransferOffers(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received GetTransferOffers request")
+
+	// Extract 'participantId' or 'assetId' from query parameters
+	function, arg := "GetTransferOffers", r.URL.Query().Get("participantId")
+	if arg == "" {
+		function, arg = "GetAssetTransferOffers", r.URL.Query().Get("assetId")
+	}
+	if arg == "" {
+		http.Error(w, "Query parameter 'participantId' or 'assetId' is missing", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Evaluate transaction using the chosen query function from chaincode
+	result, err := contract.EvaluateTransaction(function, arg)
+	if err != nil {
+		http.Error(w, "Error querying "+function+": "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(result, &data); err != nil {
+		http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with data
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+// RejectTransfer declines a transfer offer made to a participant in this organization
+func (setup *OrgSetup) RejectTransfer(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received RejectTransfer request")
+
+	// Define a structure for the expected JSON payload
+	type Request struct {
+		OfferID string `json:"offerId"`
+		Reason  string `json:"reason"`
+	}
+
+	var requestData Request
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if requestData.OfferID == "" {
+		http.Error(w, "Field 'offerId' is missing", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Submit transaction to the ledger to close the offer
+	_, err := contract.SubmitTransaction("RejectTransfer", requestData.OfferID, requestData.Reason)
+	if err != nil {
+		http.Error(w, "Error invoking RejectTransfer: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with the offer ID
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Transfer rejected successfully", "offerId": requestData.OfferID})
+}
@@ -21,6 +21,12 @@ type OrgSetup struct {
 	Gateway      client.Gateway
 	Chaincode    string
 	Channel      string
+	// CheckpointPath is the file where event listeners record how far they have read
+	CheckpointPath string
+	Checkpoints    *CheckpointStore
+	// IndexPath is the SQLite file of the off-chain asset index; the index is disabled when it is empty
+	IndexPath string
+	Index     *AssetIndex
 }
 
 // Serve initializes and starts the HTTP server
@@ -30,8 +36,34 @@ func Serve(setups OrgSetup) {
 	// Define routes for direct endpoints
 	mux.HandleFunc("/newEntry", setups.CreateAsset)
 	mux.HandleFunc("/farmerUpdate", setups.FarmerUpdateAsset)
+	mux.HandleFunc("/recall", setups.RecallAsset)
+	mux.HandleFunc("/participants", setups.Participants)
+	mux.HandleFunc("/shipments", setups.Shipments)
+	mux.HandleFunc("/shipments/dispatch", setups.DispatchShipment)
+	mux.HandleFunc("/shipments/cancel", setups.CancelShipment)
+	mux.HandleFunc("/transfers", setups.GetTransferOffers)
+	mux.HandleFunc("/transfers/offer", setups.OfferTransfer)
 	mux.HandleFunc("/getAll", setups.GetAllAssets)
 	mux.HandleFunc("/getEntry", setups.ReadAsset)
+	mux.HandleFunc("/history", setups.GetAssetHistory)
+	mux.HandleFunc("/prices", setups.GetAssetPrices)
+	mux.HandleFunc("/prices/query", setups.QueryPrices)
+	mux.HandleFunc("/lineage", setups.GetLineage)
+	mux.HandleFunc("/inspections", setups.Inspections)
+	mux.HandleFunc("/telemetry", setups.Telemetry)
+	mux.HandleFunc("/recallImpact", setups.GetRecallImpact)
+	mux.HandleFunc("/inventory", setups.GetInventory)
+	mux.HandleFunc("/getBy", setups.GetAssetsBy)
+	mux.HandleFunc("/query", setups.QueryAssets)
+	mux.HandleFunc("/events", setups.StreamEvents)
+	mux.HandleFunc("/index/search", setups.SearchIndex)
+	mux.HandleFunc("/index/stats", setups.GetIndexStats)
+	mux.HandleFunc("/index/suppliers", setups.GetSuppliers)
+
+	// Keep the off-chain asset index in sync with the ledger
+	if setups.Index != nil {
+		setups.startIndexSync()
+	}
 
 	// Wrap the mux with the logging middleware
 	loggedMux := loggingMiddleware(mux)
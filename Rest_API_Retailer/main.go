@@ -1,7 +1,10 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"log"
+	"os"
 	"rest-api-go/web"
 )
 
@@ -9,20 +12,48 @@ func main() {
 	//Initialize setup for Org3
 	cryptoPath := "../Blockchain_Configuration/crypto-config/peerOrganizations/org3.example.com"
 	orgConfig := web.OrgSetup{
-		OrgName:      "Org3",
-		MSPID:        "Org3MSP",
-		CertPath:     cryptoPath + "/users/User1@org3.example.com/msp/signcerts/User1@org3.example.com-cert.pem",
-		KeyPath:      cryptoPath + "/users/User1@org3.example.com/msp/keystore/",
-		TLSCertPath:  cryptoPath + "/peers/peer0.org3.example.com/tls/ca.crt",
-		PeerEndpoint: "dns:///localhost:9151",
-		GatewayPeer:  "peer0.org3.example.com",
-		Chaincode:    "toma-trace",
-		Channel:      "mychannel",
+		OrgName:        "Org3",
+		MSPID:          "Org3MSP",
+		CertPath:       cryptoPath + "/users/User1@org3.example.com/msp/signcerts/User1@org3.example.com-cert.pem",
+		KeyPath:        cryptoPath + "/users/User1@org3.example.com/msp/keystore/",
+		TLSCertPath:    cryptoPath + "/peers/peer0.org3.example.com/tls/ca.crt",
+		PeerEndpoint:   "dns:///localhost:9151",
+		GatewayPeer:    "peer0.org3.example.com",
+		Chaincode:      "toma-trace",
+		Channel:        "mychannel",
+		CheckpointPath: "checkpoints.json",
+		IndexPath:      "index.db",
+		// Consumer endpoints listen apart from the API; QR codes link to PUBLIC_URL and are off without it
+		PublicAddr: ":3003",
+		PublicURL:  os.Getenv("PUBLIC_URL"),
 	}
 
 	orgSetup, err := web.Initialize(orgConfig)
 	if err != nil {
-		fmt.Println("Error initializing setup for Org3: ", err)
+		log.Fatalf("Error initializing setup for Org3: %v", err)
 	}
+
+	// "reconcile" checks the asset index against the ledger instead of serving; "-fix" repairs it
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		fix := len(os.Args) > 2 && os.Args[2] == "-fix"
+		os.Exit(reconcile(orgSetup, fix))
+	}
+
 	web.Serve(web.OrgSetup(*orgSetup))
 }
+
+// reconcile prints the differences between the asset index and the ledger and returns the exit status
+func reconcile(orgSetup *web.OrgSetup, fix bool) int {
+	report, err := orgSetup.ReconcileIndex(fix)
+	if err != nil {
+		fmt.Println("Error reconciling the asset index: ", err)
+		return 2
+	}
+
+	output, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(output))
+	if !report.Consistent() && !report.Fixed {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,47 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// AcceptTransfer accepts a wholesaler's transfer offer, moving the lot into the retailer's custody
+func (setup *OrgSetup) AcceptTransfer(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received AcceptTransfer request")
+
+	// Define a structure for the expected JSON payload
+	type Request struct {
+		OfferID string `json:"offerId"`
+	}
+
+	var requestData Request
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if requestData.OfferID == "" {
+		http.Error(w, "Field 'offerId' is missing", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Submit transaction to the ledger to take custody; the offer price is copied within the private data
+	// collection the wholesaler and retailer share, so only they endorse it
+	_, err := contract.Submit("AcceptTransfer",
+		client.WithArguments(requestData.OfferID),
+		client.WithEndorsingOrganizations("Org2MSP", "Org3MSP"))
+	if err != nil {
+		http.Error(w, "Error invoking AcceptTransfer: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with the offer ID
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Transfer accepted successfully", "offerId": requestData.OfferID})
+}
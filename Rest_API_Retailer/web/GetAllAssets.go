@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 )
 
 func (setup *OrgSetup) GetAllAssets(w http.ResponseWriter, r *http.Request) {
@@ -12,6 +13,22 @@ func (setup *OrgSetup) GetAllAssets(w http.ResponseWriter, r *http.Request) {
 	network := setup.Gateway.GetNetwork(setup.Channel)
 	contract := network.GetContract(setup.Chaincode)
 
+	// Page through the ledger when the client asks for it
+	pageSize, bookmark, paginated, err := paginationParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if paginated {
+		result, err := contract.EvaluateTransaction("GetAssetsWithPagination", pageSize, bookmark)
+		if err != nil {
+			http.Error(w, "Error querying GetAssetsWithPagination: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writePage(w, result)
+		return
+	}
+
 	// Evaluate transaction using the GetAllData function from chaincode
 	result, err := contract.EvaluateTransaction("GetAllAssets")
 	if err != nil {
@@ -30,3 +47,46 @@ func (setup *OrgSetup) GetAllAssets(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(data)
 }
+
+// defaultPageSize is used when a client sends a bookmark without a page size
+const defaultPageSize = "100"
+
+// paginationParams reads the 'pageSize' and 'bookmark' query parameters.
+// The request is paginated when either of them is present.
+func paginationParams(r *http.Request) (pageSize string, bookmark string, paginated bool, err error) {
+	pageSize = r.URL.Query().Get("pageSize")
+	bookmark = r.URL.Query().Get("bookmark")
+	if pageSize == "" && bookmark == "" {
+		return "", "", false, nil
+	}
+
+	if pageSize == "" {
+		pageSize = defaultPageSize
+	}
+	size, err := strconv.ParseInt(pageSize, 10, 32)
+	if err != nil || size <= 0 {
+		return "", "", false, fmt.Errorf("Query parameter 'pageSize' must be a positive integer")
+	}
+
+	return pageSize, bookmark, true, nil
+}
+
+// writePage sends a page of assets with the bookmark the client passes to fetch the next one
+func writePage(w http.ResponseWriter, result []byte) {
+	var page struct {
+		Records             []interface{} `json:"Records"`
+		FetchedRecordsCount int32         `json:"FetchedRecordsCount"`
+		Bookmark            string        `json:"Bookmark"`
+	}
+	if err := json.Unmarshal(result, &page); err != nil {
+		http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"records":             page.Records,
+		"fetchedRecordsCount": page.FetchedRecordsCount,
+		"bookmark":            page.Bookmark,
+	})
+}
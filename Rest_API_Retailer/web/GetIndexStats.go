@@ -0,0 +1,37 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// GetIndexStats returns asset counts and total quantities from the off-chain index, grouped by the
+// 'groupBy' query parameter: variety, status, farmerId, wholesalerId, retailerId or harvestMonth
+func (setup *OrgSetup) GetIndexStats(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Get Index Stats request")
+
+	if setup.Index == nil {
+		http.Error(w, "The asset index is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	groupBy := r.URL.Query().Get("groupBy")
+	if groupBy == "" {
+		groupBy = "variety"
+	}
+	if _, ok := statsColumns[groupBy]; !ok {
+		http.Error(w, "Query parameter 'groupBy' must be one of variety, status, farmerId, wholesalerId, retailerId or harvestMonth", http.StatusBadRequest)
+		return
+	}
+
+	stats, err := setup.Index.Stats(groupBy)
+	if err != nil {
+		http.Error(w, "Error aggregating the asset index: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with data
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,35 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// GetSuppliers returns, from the off-chain index, the farmers whose harvest went into a retailer's lots
+// and the quantity of those lots, following splits and merges back to the original harvest lots
+func (setup *OrgSetup) GetSuppliers(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Get Suppliers request")
+
+	if setup.Index == nil {
+		http.Error(w, "The asset index is not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Extract 'retailerId' from query parameters
+	retailerId := r.URL.Query().Get("retailerId")
+	if retailerId == "" {
+		http.Error(w, "Query parameter 'retailerId' is missing", http.StatusBadRequest)
+		return
+	}
+
+	suppliers, err := setup.Index.Suppliers(retailerId)
+	if err != nil {
+		http.Error(w, "Error querying the asset index: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with data
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suppliers)
+}
@@ -0,0 +1,132 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// provenanceView is the sanitized, consumer-facing provenance of an asset. It names the farms and the
+// businesses that held the lot, but leaves out supplier IDs, prices and quantities.
+type provenanceView struct {
+	ID       string         `json:"id"`
+	Variety  string         `json:"variety"`
+	Status   string         `json:"status"`
+	Recalled bool           `json:"recalled"`
+	Origins  []originView   `json:"origins"`
+	Custody  []custodyEvent `json:"custody"`
+}
+
+// originView is a harvest lot the asset came from
+type originView struct {
+	FarmerName   string `json:"farmerName"`
+	FarmLocation string `json:"farmLocation"`
+	Variety      string `json:"variety"`
+	HarvestDate  string `json:"harvestDate"`
+}
+
+// custodyEvent is one step in the chain of custody
+type custodyEvent struct {
+	Stage string `json:"stage"`
+	Name  string `json:"name"`
+	Date  string `json:"date"`
+}
+
+// publicAsset holds the asset fields the provenance view is built from
+type publicAsset struct {
+	ID                string `json:"ID"`
+	FarmerName        string `json:"FarmerName"`
+	FarmLocation      string `json:"FarmLocation"`
+	Variety           string `json:"Variety"`
+	HarvestDate       string `json:"HarvestDate"`
+	WholesalerName    string `json:"WholesalerName"`
+	WholesalerBuyDate string `json:"WholesalerBuyDate"`
+	RetailerName      string `json:"RetailerName"`
+	RetailerBuyDate   string `json:"RetailerBuyDate"`
+	Status            string `json:"Status"`
+	RecallReason      string `json:"RecallReason"`
+}
+
+// PublicProvenance returns the sanitized provenance of an asset as JSON. It is read-only and meant to be
+// called without credentials, for example by the consumer page a pack's QR code links to.
+func (setup *OrgSetup) PublicProvenance(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Public Provenance request")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract 'id' from query parameters
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Query parameter 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	view, err := setup.provenance(id)
+	if err != nil {
+		http.Error(w, "Asset not found", http.StatusNotFound)
+		return
+	}
+
+	// Send the response with data
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(view)
+}
+
+// provenance builds the sanitized provenance of an asset from the asset and the harvest lots it came from
+func (setup *OrgSetup) provenance(id string) (*provenanceView, error) {
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	result, err := contract.EvaluateTransaction("ReadAsset", id)
+	if err != nil {
+		return nil, err
+	}
+	var asset publicAsset
+	if err := json.Unmarshal(result, &asset); err != nil {
+		return nil, err
+	}
+
+	result, err = contract.EvaluateTransaction("GetOriginAssets", id)
+	if err != nil {
+		return nil, err
+	}
+	var origins []publicAsset
+	if len(result) > 0 {
+		if err := json.Unmarshal(result, &origins); err != nil {
+			return nil, err
+		}
+	}
+
+	view := &provenanceView{
+		ID:       asset.ID,
+		Variety:  asset.Variety,
+		Status:   asset.Status,
+		Recalled: asset.RecallReason != "",
+		Origins:  []originView{},
+		Custody:  []custodyEvent{},
+	}
+	for _, origin := range origins {
+		view.Origins = append(view.Origins, originView{
+			FarmerName:   origin.FarmerName,
+			FarmLocation: origin.FarmLocation,
+			Variety:      origin.Variety,
+			HarvestDate:  origin.HarvestDate,
+		})
+	}
+
+	if asset.HarvestDate != "" {
+		view.Custody = append(view.Custody, custodyEvent{Stage: "Harvested", Name: asset.FarmerName, Date: asset.HarvestDate})
+	}
+	if asset.WholesalerBuyDate != "" {
+		view.Custody = append(view.Custody, custodyEvent{Stage: "Wholesaler", Name: asset.WholesalerName, Date: asset.WholesalerBuyDate})
+	}
+	if asset.RetailerBuyDate != "" {
+		view.Custody = append(view.Custody, custodyEvent{Stage: "Retailer", Name: asset.RetailerName, Date: asset.RetailerBuyDate})
+	}
+
+	return view, nil
+}
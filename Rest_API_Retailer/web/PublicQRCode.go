@@ -0,0 +1,95 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Sizes of a generated QR code, in pixels for PNG and in modules for SVG
+const (
+	defaultQRSize = 256
+	maxQRSize     = 2048
+)
+
+// PublicQRCode returns a QR code linking to the consumer trace page of an asset, for printing on packs.
+// The 'format' query parameter selects png (the default) or svg, and 'size' the PNG width in pixels.
+// Codes are only generated when a public URL is configured, so a pack never links to an address taken
+// from the request.
+func (setup *OrgSetup) PublicQRCode(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Public QR Code request")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if setup.PublicURL == "" {
+		http.Error(w, "QR codes are disabled: no public URL is configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Extract 'id' from query parameters
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Query parameter 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	size := defaultQRSize
+	if sizeParam := r.URL.Query().Get("size"); sizeParam != "" {
+		value, err := strconv.Atoi(sizeParam)
+		if err != nil || value <= 0 || value > maxQRSize {
+			http.Error(w, fmt.Sprintf("Query parameter 'size' must be between 1 and %d", maxQRSize), http.StatusBadRequest)
+			return
+		}
+		size = value
+	}
+
+	code, err := qrcode.New(setup.traceURL(id), qrcode.Medium)
+	if err != nil {
+		http.Error(w, "Error generating QR code: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "png":
+		png, err := code.PNG(size)
+		if err != nil {
+			http.Error(w, "Error generating QR code: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(png)
+	case "svg":
+		w.Header().Set("Content-Type", "image/svg+xml")
+		fmt.Fprint(w, qrCodeSVG(code.Bitmap()))
+	default:
+		http.Error(w, "Query parameter 'format' must be png or svg", http.StatusBadRequest)
+	}
+}
+
+// traceURL is the address of the consumer trace page of an asset under the configured public URL
+func (setup *OrgSetup) traceURL(id string) string {
+	return strings.TrimSuffix(setup.PublicURL, "/") + "/public/trace?id=" + url.QueryEscape(id)
+}
+
+// qrCodeSVG draws a QR code bitmap as an SVG with one unit per module, so it scales to any print size
+func qrCodeSVG(bitmap [][]bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, len(bitmap), len(bitmap))
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, len(bitmap), len(bitmap))
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	b.WriteString(`"/></svg>`)
+
+	return b.String()
+}
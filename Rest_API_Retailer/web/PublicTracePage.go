@@ -0,0 +1,80 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// tracePage renders the provenance view for consumers. html/template escapes every ledger value.
+var tracePage = template.Must(template.New("trace").Funcs(template.FuncMap{"date": displayDate}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Where your tomatoes come from</title>
+<style>
+body { font-family: sans-serif; margin: 0 auto; max-width: 36rem; padding: 1rem; color: #222; }
+h1 { color: #b3261e; font-size: 1.5rem; }
+.recall { background: #b3261e; color: #fff; padding: 0.75rem; border-radius: 0.25rem; }
+ol { padding-left: 1.25rem; }
+li { margin-bottom: 0.5rem; }
+.muted { color: #666; font-size: 0.9rem; }
+</style>
+</head>
+<body>
+<h1>{{if .Variety}}{{.Variety}} tomatoes{{else}}Your tomatoes{{end}}</h1>
+{{if .Recalled}}<p class="recall">This product has been recalled. Please do not eat it and return it to the store.</p>{{end}}
+<h2>Grown by</h2>
+<ul>
+{{range .Origins}}<li>{{if .FarmerName}}{{.FarmerName}}{{else}}A registered farm{{end}}{{if .FarmLocation}}, {{.FarmLocation}}{{end}}<br><span class="muted">{{.Variety}} harvested {{date .HarvestDate}}</span></li>
+{{else}}<li>Origin not recorded</li>
+{{end}}</ul>
+<h2>Journey</h2>
+<ol>
+{{range .Custody}}<li>{{.Stage}}{{if .Name}}: {{.Name}}{{end}}<br><span class="muted">{{date .Date}}</span></li>
+{{end}}</ol>
+<p class="muted">Lot {{.ID}} is traced on the TomaTrace blockchain.</p>
+</body>
+</html>
+`))
+
+// PublicTracePage shows consumers the sanitized provenance of an asset as a web page. It is the page a
+// pack's QR code links to and needs no credentials.
+func (setup *OrgSetup) PublicTracePage(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Public Trace Page request")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract 'id' from query parameters
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Query parameter 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	view, err := setup.provenance(id)
+	if err != nil {
+		http.Error(w, "We could not find this product", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tracePage.Execute(w, view); err != nil {
+		fmt.Println("Error rendering trace page: ", err)
+	}
+}
+
+// displayDate shows an RFC3339 ledger date as a calendar date, leaving other values as they are
+func displayDate(date string) string {
+	parsed, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return date
+	}
+
+	return parsed.Format("2 January 2006")
+}
@@ -0,0 +1,58 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// QueryPrices filters the prices of kind ?kind= (Harvest, Wholesale or Retail) that this organization is
+// party to. The request body is a CouchDB query or selector over the price records, for example
+// {"Price": {"$gte": 500, "$lte": 900}}, with prices in minor units of their currency.
+func (setup *OrgSetup) QueryPrices(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Query Prices request")
+
+	// Extract 'kind' from query parameters
+	kind := r.URL.Query().Get("kind")
+	if kind == "" {
+		http.Error(w, "Query parameter 'kind' is missing", http.StatusBadRequest)
+		return
+	}
+
+	// The request body is a CouchDB query or selector, passed through to the chaincode
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !json.Valid(body) {
+		http.Error(w, "Request body must be a JSON query or selector", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Evaluate transaction using the QueryPrices function from chaincode
+	result, err := contract.EvaluateTransaction("QueryPrices", kind, string(body))
+	if err != nil {
+		http.Error(w, "Error querying QueryPrices: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var data []interface{}
+	if len(result) > 0 {
+		if err := json.Unmarshal(result, &data); err != nil {
+			http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if data == nil {
+		data = []interface{}{}
+	}
+
+	// Send the response with data
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
@@ -3,6 +3,7 @@ package web
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 )
 
@@ -13,52 +14,47 @@ func (setup *OrgSetup) RetailerUpdateAsset(w http.ResponseWriter, r *http.Reques
 	type Request struct {
 		ID              string `json:"id"`
 		RetailerId      string `json:"retailerId"`
-		RetailerName    string `json:"retailername"`
+		RetailerName    string `json:"retailerName"`
 		RetailerBuyDate string `json:"retailerBuyDate"`
 	}
 
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	var requestData Request
-	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+	if err := json.Unmarshal(body, &requestData); err != nil {
 		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	network := setup.Gateway.GetNetwork(setup.Channel)
-	contract := network.GetContract(setup.Chaincode)
-
-	// Retrieve existing asset data
-	result, err := contract.EvaluateTransaction("ReadAsset", requestData.ID)
+	// The price, if any, travels in the transient map rather than the public payload
+	transient, err := priceTransient(body)
 	if err != nil {
-		http.Error(w, "Error invoking ReadAsset: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Unmarshal the asset data to update it
-	var asset map[string]string
-	if err := json.Unmarshal(result, &asset); err != nil {
-		http.Error(w, "JSON Unmarshal error: "+err.Error(), http.StatusInternalServerError)
+	if requestData.ID == "" {
+		http.Error(w, "Field 'id' is missing", http.StatusBadRequest)
 		return
 	}
 
-	// Update the asset with the new retailer information
-	if requestData.RetailerId != "" {
-
-		asset["RetailerId"] = requestData.RetailerId
-	}
-	if requestData.RetailerName != "" {
-
-		asset["RetailerName"] = requestData.RetailerName
-	}
-	if requestData.RetailerBuyDate != "" {
-
-		asset["RetailerBuyDate"] = requestData.RetailerBuyDate
+	payload, err := json.Marshal(requestData)
+	if err != nil {
+		http.Error(w, "JSON Marshal error: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	// Submit transaction to the ledger to update the asset
-	_, err = contract.SubmitTransaction("UpdateAsset", requestData.ID, asset["FarmerId"], asset["FarmLocation"], asset["Variety"], asset["BatchNo"], asset["HarvestDate"], asset["Price"], asset["Quantity"], asset["WholesalerId"], asset["WholesalerPrice"], asset["WholesalerBuyDate"], asset["RetailerId"], asset["RetailerBuyDate"])
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
 
+	// Submit transaction to the ledger to merge the retailer purchase into the asset
+	_, err = contract.Submit("RecordRetailPurchase", priceOptions(string(payload), transient, "Org2MSP", "Org3MSP")...)
 	if err != nil {
-		http.Error(w, "Error invoking UpdateAsset: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Error invoking RecordRetailPurchase: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -0,0 +1,166 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// saleLine is one sale of a POS export, in the unit of the lot it was sold from
+type saleLine struct {
+	AssetID  string  `json:"assetId"`
+	Quantity float64 `json:"quantity"`
+	SaleDate string  `json:"saleDate"`
+}
+
+// Sales records and lists retail sales: GET ?id= lists the sales of a lot and POST records a POS export,
+// either a JSON array of {assetId, quantity, saleDate} or, with Content-Type text/csv, a CSV file with a
+// header row naming those columns. Lines that cannot be recorded are listed in the response.
+func (setup *OrgSetup) Sales(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Sales request")
+
+	switch r.Method {
+	case http.MethodGet:
+		setup.getSales(w, r)
+	case http.MethodPost:
+		setup.recordSales(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (setup *OrgSetup) getSales(w http.ResponseWriter, r *http.Request) {
+	// Extract 'id' from query parameters
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Query parameter 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Evaluate transaction using the GetSales function from chaincode
+	result, err := contract.EvaluateTransaction("GetSales", id)
+	if err != nil {
+		http.Error(w, "Error querying GetSales: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(result, &data); err != nil {
+		http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with data
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+func (setup *OrgSetup) recordSales(w http.ResponseWriter, r *http.Request) {
+	var lines []saleLine
+	var err error
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		lines, err = parseSalesCSV(r.Body)
+	} else {
+		err = json.NewDecoder(r.Body).Decode(&lines)
+	}
+	if err != nil {
+		http.Error(w, "Error reading POS export: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(lines) == 0 {
+		http.Error(w, "The POS export has no sales", http.StatusBadRequest)
+		return
+	}
+
+	payload, err := json.Marshal(lines)
+	if err != nil {
+		http.Error(w, "JSON Marshal error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Submit transaction to the ledger to record the batch
+	result, err := contract.SubmitTransaction("RecordSales", string(payload))
+	if err != nil {
+		http.Error(w, "Error invoking RecordSales: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(result, &data); err != nil {
+		http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with the batch report
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+// parseSalesCSV reads a CSV POS export. The header row names the assetId, quantity and saleDate columns in any
+// order; case, spaces and underscores in the names are ignored and other columns are skipped.
+func parseSalesCSV(body io.Reader) ([]saleLine, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("missing header row: %v", err)
+	}
+	columns := map[string]int{"assetid": -1, "quantity": -1, "saledate": -1}
+	for i, name := range header {
+		name = strings.ToLower(strings.NewReplacer(" ", "", "_", "").Replace(name))
+		if _, ok := columns[name]; ok {
+			columns[name] = i
+		}
+	}
+	for _, name := range []string{"assetid", "quantity", "saledate"} {
+		if columns[name] < 0 {
+			return nil, fmt.Errorf("the header row has no %s column", name)
+		}
+	}
+
+	var lines []saleLine
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		field := func(name string) string {
+			if columns[name] >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[columns[name]])
+		}
+		quantity, err := strconv.ParseFloat(field("quantity"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid quantity %q", row, field("quantity"))
+		}
+		lines = append(lines, saleLine{
+			AssetID:  field("assetid"),
+			Quantity: quantity,
+			SaleDate: field("saledate"),
+		})
+	}
+
+	return lines, nil
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Shipments reads shipments: GET ?id= reads a shipment and GET ?assetId= lists the shipments that carried
+// a lot. Retailers are the last stage and only receive shipments, so they cannot plan, dispatch or cancel one.
+func (setup *OrgSetup) Shipments(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Shipments request")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	setup.getShipments(w, r)
+}
+
+// ReceiveShipment confirms delivery of a shipment, moving the lots it carries into this organization's custody
+func (setup *OrgSetup) ReceiveShipment(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received ReceiveShipment request")
+	setup.submitShipmentStep(w, r, "ReceiveShipment", "Shipment received successfully")
+}
+
+func (setup *OrgSetup) getShipments(w http.ResponseWriter, r *http.Request) {
+	// Extract 'id' or 'assetId' from query parameters
+	function, arg := "GetShipment", r.URL.Query().Get("id")
+	if arg == "" {
+		function, arg = "GetAssetShipments", r.URL.Query().Get("assetId")
+	}
+	if arg == "" {
+		http.Error(w, "Query parameter 'id' or 'assetId' is missing", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Evaluate transaction using the chosen query function from chaincode
+	result, err := contract.EvaluateTransaction(function, arg)
+	if err != nil {
+		http.Error(w, "Error querying "+function+": "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(result, &data); err != nil {
+		http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with data
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+// submitShipmentStep moves the shipment named in the payload on to its next state
+func (setup *OrgSetup) submitShipmentStep(w http.ResponseWriter, r *http.Request, function, message string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Define a structure for the expected JSON payload
+	type Request struct {
+		ID string `json:"id"`
+	}
+
+	var requestData Request
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if requestData.ID == "" {
+		http.Error(w, "Field 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Submit transaction to the ledger to update the shipment
+	_, err := contract.SubmitTransaction(function, requestData.ID)
+	if err != nil {
+		http.Error(w, "Error invoking "+function+": "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with the shipment ID
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": message, "id": requestData.ID})
+}
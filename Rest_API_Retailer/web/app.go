@@ -22,6 +22,18 @@ type OrgSetup struct {
 	Gateway      client.Gateway
 	Chaincode    string
 	Channel      string
+	// CheckpointPath is the file where event listeners record how far they have read
+	CheckpointPath string
+	Checkpoints    *CheckpointStore
+	// IndexPath is the SQLite file of the off-chain asset index; the index is disabled when it is empty
+	IndexPath string
+	Index     *AssetIndex
+	// PublicURL is the base address printed in pack QR codes, such as https://trace.example.com. QR codes
+	// are not generated without it.
+	PublicURL string
+	// PublicAddr is the listen address of the consumer endpoints, kept apart from the internal API; the
+	// consumer endpoints are not served when it is empty
+	PublicAddr string
 }
 
 var clientsMutex sync.Mutex
@@ -32,8 +44,40 @@ func Serve(setups OrgSetup) {
 
 	// Define routes for direct endpoints
 	mux.HandleFunc("/retailerUpdate", setups.RetailerUpdateAsset)
+	mux.HandleFunc("/participants", setups.Participants)
+	mux.HandleFunc("/shipments", setups.Shipments)
+	mux.HandleFunc("/shipments/receive", setups.ReceiveShipment)
+	mux.HandleFunc("/transfers", setups.GetTransferOffers)
+	mux.HandleFunc("/transfers/accept", setups.AcceptTransfer)
+	mux.HandleFunc("/transfers/reject", setups.RejectTransfer)
+	mux.HandleFunc("/sales", setups.Sales)
 	mux.HandleFunc("/getAll", setups.GetAllAssets)
 	mux.HandleFunc("/getEntry", setups.ReadAsset)
+	mux.HandleFunc("/history", setups.GetAssetHistory)
+	mux.HandleFunc("/prices", setups.GetAssetPrices)
+	mux.HandleFunc("/prices/query", setups.QueryPrices)
+	mux.HandleFunc("/lineage", setups.GetLineage)
+	mux.HandleFunc("/inspections", setups.Inspections)
+	mux.HandleFunc("/telemetry", setups.Telemetry)
+	mux.HandleFunc("/recallImpact", setups.GetRecallImpact)
+	mux.HandleFunc("/inventory", setups.GetInventory)
+	mux.HandleFunc("/getBy", setups.GetAssetsBy)
+	mux.HandleFunc("/query", setups.QueryAssets)
+	mux.HandleFunc("/events", setups.StreamEvents)
+	mux.HandleFunc("/index/search", setups.SearchIndex)
+	mux.HandleFunc("/index/stats", setups.GetIndexStats)
+	mux.HandleFunc("/index/suppliers", setups.GetSuppliers)
+
+	// Keep the off-chain asset index in sync with the ledger
+	if setups.Index != nil {
+		setups.startIndexSync()
+	}
+
+	// Read-only consumer endpoints, reached from the QR codes on packs without credentials. They have their
+	// own listener so exposing them does not expose the internal API.
+	if setups.PublicAddr != "" {
+		go servePublic(setups)
+	}
 
 	// Wrap the mux with the logging middleware
 	loggedMux := loggingMiddleware(mux)
@@ -44,6 +88,19 @@ func Serve(setups OrgSetup) {
 	}
 }
 
+// servePublic starts the HTTP server of the consumer endpoints, which serves nothing else
+func servePublic(setups OrgSetup) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/public/provenance", setups.PublicProvenance)
+	mux.HandleFunc("/public/trace", setups.PublicTracePage)
+	mux.HandleFunc("/public/qr", setups.PublicQRCode)
+
+	fmt.Printf("Serving consumer endpoints on %s ...\n", setups.PublicAddr)
+	if err := http.ListenAndServe(setups.PublicAddr, loggingMiddleware(mux)); err != nil {
+		log.Fatal("ListenAndServe Error:", err)
+	}
+}
+
 // loggingMiddleware logs all incoming HTTP requests
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
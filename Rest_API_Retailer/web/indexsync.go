@@ -0,0 +1,142 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go-apiv2/msp"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+// indexListener is the name the index sync checkpoints its position under
+const indexListener = "sql-index"
+
+// compositeKeyNamespace starts every composite key, such as the chaincode's index entries; asset keys never do
+const compositeKeyNamespace = "\x00"
+
+// SyncIndex keeps the asset index in step with the ledger until ctx is cancelled, replaying the chain from
+// the genesis block the first time it runs
+func (setup *OrgSetup) SyncIndex(ctx context.Context) error {
+	return setup.ListenBlockEvents(ctx, indexListener, 0, func(block *common.Block) error {
+		writes, err := setup.assetWrites(block)
+		if err != nil {
+			return err
+		}
+		return setup.Index.ApplyBlock(block.GetHeader().GetNumber(), writes)
+	})
+}
+
+// assetWrites decodes the asset keys written by valid toma-trace transactions in a block
+func (setup *OrgSetup) assetWrites(block *common.Block) ([]*assetWrite, error) {
+	var validationCodes []byte
+	if metadata := block.GetMetadata().GetMetadata(); len(metadata) > int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		validationCodes = metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+
+	var writes []*assetWrite
+	for i, envelopeBytes := range block.GetData().GetData() {
+		if i >= len(validationCodes) || peer.TxValidationCode(validationCodes[i]) != peer.TxValidationCode_VALID {
+			continue
+		}
+
+		txWrites, err := setup.transactionWrites(envelopeBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode transaction %d of block %d: %w", i, block.GetHeader().GetNumber(), err)
+		}
+		writes = append(writes, txWrites...)
+	}
+
+	return writes, nil
+}
+
+// transactionWrites decodes the writes an endorser transaction made to the chaincode's namespace
+func (setup *OrgSetup) transactionWrites(envelopeBytes []byte) ([]*assetWrite, error) {
+	envelope := &common.Envelope{}
+	if err := proto.Unmarshal(envelopeBytes, envelope); err != nil {
+		return nil, err
+	}
+	payload := &common.Payload{}
+	if err := proto.Unmarshal(envelope.GetPayload(), payload); err != nil {
+		return nil, err
+	}
+	channelHeader := &common.ChannelHeader{}
+	if err := proto.Unmarshal(payload.GetHeader().GetChannelHeader(), channelHeader); err != nil {
+		return nil, err
+	}
+	if common.HeaderType(channelHeader.GetType()) != common.HeaderType_ENDORSER_TRANSACTION {
+		return nil, nil
+	}
+
+	signatureHeader := &common.SignatureHeader{}
+	if err := proto.Unmarshal(payload.GetHeader().GetSignatureHeader(), signatureHeader); err != nil {
+		return nil, err
+	}
+	creator := &msp.SerializedIdentity{}
+	if err := proto.Unmarshal(signatureHeader.GetCreator(), creator); err != nil {
+		return nil, err
+	}
+
+	transaction := &peer.Transaction{}
+	if err := proto.Unmarshal(payload.GetData(), transaction); err != nil {
+		return nil, err
+	}
+
+	var writes []*assetWrite
+	for _, action := range transaction.GetActions() {
+		actionPayload := &peer.ChaincodeActionPayload{}
+		if err := proto.Unmarshal(action.GetPayload(), actionPayload); err != nil {
+			return nil, err
+		}
+		responsePayload := &peer.ProposalResponsePayload{}
+		if err := proto.Unmarshal(actionPayload.GetAction().GetProposalResponsePayload(), responsePayload); err != nil {
+			return nil, err
+		}
+		chaincodeAction := &peer.ChaincodeAction{}
+		if err := proto.Unmarshal(responsePayload.GetExtension(), chaincodeAction); err != nil {
+			return nil, err
+		}
+		readWriteSet := &rwset.TxReadWriteSet{}
+		if err := proto.Unmarshal(chaincodeAction.GetResults(), readWriteSet); err != nil {
+			return nil, err
+		}
+
+		for _, namespace := range readWriteSet.GetNsRwset() {
+			if namespace.GetNamespace() != setup.Chaincode {
+				continue
+			}
+			kvSet := &kvrwset.KVRWSet{}
+			if err := proto.Unmarshal(namespace.GetRwset(), kvSet); err != nil {
+				return nil, err
+			}
+			for _, write := range kvSet.GetWrites() {
+				if strings.HasPrefix(write.GetKey(), compositeKeyNamespace) {
+					continue
+				}
+				writes = append(writes, &assetWrite{
+					AssetID:   write.GetKey(),
+					TxID:      channelHeader.GetTxId(),
+					MSPID:     creator.GetMspid(),
+					Timestamp: channelHeader.GetTimestamp().AsTime(),
+					IsDelete:  write.GetIsDelete(),
+					Value:     write.GetValue(),
+				})
+			}
+		}
+	}
+
+	return writes, nil
+}
+
+// startIndexSync runs the index sync in the background, logging why it stopped
+func (setup *OrgSetup) startIndexSync() {
+	go func() {
+		err := setup.SyncIndex(context.Background())
+		log.Printf("Asset index sync stopped: %v", err)
+	}()
+}
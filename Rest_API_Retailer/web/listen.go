@@ -0,0 +1,62 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+)
+
+// listenRetryInterval is how long a listener waits before reconnecting after its event stream ends
+const listenRetryInterval = 5 * time.Second
+
+// ListenBlockEvents passes blocks to handler in order until ctx is cancelled or handler fails, resuming
+// after the last block the named listener checkpointed, or from startBlock the first time it runs.
+// A block is checkpointed only once handler returns without error.
+func (setup *OrgSetup) ListenBlockEvents(ctx context.Context, listener string, startBlock uint64, handler func(*common.Block) error) error {
+	if setup.Checkpoints == nil {
+		return fmt.Errorf("no checkpoint store is configured for listener %s", listener)
+	}
+
+	// Cancelling closes the event stream when the handler fails and the listener returns
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	checkpointer := setup.Checkpoints.Checkpointer(listener)
+	network := setup.Gateway.GetNetwork(setup.Channel)
+
+	for {
+		blocks, err := network.BlockEvents(ctx, client.WithStartBlock(startBlock), client.WithCheckpoint(checkpointer))
+		if err != nil {
+			log.Printf("Listener %s failed to subscribe to block events: %v", listener, err)
+		} else {
+			for block := range blocks {
+				blockNumber := block.GetHeader().GetNumber()
+				if err := handler(block); err != nil {
+					return fmt.Errorf("listener %s failed on block %d: %w", listener, blockNumber, err)
+				}
+				if err := checkpointer.CheckpointBlock(blockNumber); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := waitToReconnect(ctx, listener); err != nil {
+			return err
+		}
+	}
+}
+
+// waitToReconnect pauses before a listener resubscribes, returning the context error once it is cancelled
+func waitToReconnect(ctx context.Context, listener string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(listenRetryInterval):
+		log.Printf("Listener %s reconnecting...", listener)
+		return nil
+	}
+}
@@ -0,0 +1,128 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// reconcilePageSize is the number of assets read from the ledger per query while reconciling
+const reconcilePageSize = "200"
+
+// ReconcileReport lists the differences between the asset index and the ledger world state
+type ReconcileReport struct {
+	LastBlock  uint64   `json:"lastBlock"`
+	Checked    int      `json:"checked"`
+	Missing    []string `json:"missing"`
+	Stale      []string `json:"stale"`
+	Mismatched []string `json:"mismatched"`
+	Fixed      bool     `json:"fixed"`
+}
+
+// Consistent reports whether the index matched the ledger
+func (report *ReconcileReport) Consistent() bool {
+	return len(report.Missing) == 0 && len(report.Stale) == 0 && len(report.Mismatched) == 0
+}
+
+// ReconcileIndex compares every asset in the world state with the index. Missing lists assets the index
+// lacks, Stale indexed assets no longer on the ledger and Mismatched assets whose indexed fields differ.
+// With fix set, the index is corrected from the ledger. Run it while the sync is caught up, since blocks
+// committed after the sync's last block show up as differences.
+func (setup *OrgSetup) ReconcileIndex(fix bool) (*ReconcileReport, error) {
+	if setup.Index == nil {
+		return nil, fmt.Errorf("the asset index is not enabled")
+	}
+	ledger, err := setup.ledgerDocuments()
+	if err != nil {
+		return nil, err
+	}
+
+	return setup.Index.reconcile(ledger, fix)
+}
+
+// reconcile compares the index with the ledger documents, keyed by asset ID, and repairs it if fix is set
+func (index *AssetIndex) reconcile(ledger map[string][]byte, fix bool) (*ReconcileReport, error) {
+	lastBlock, _, err := index.LastBlock()
+	if err != nil {
+		return nil, err
+	}
+	indexed, err := index.indexedDocuments()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReconcileReport{LastBlock: lastBlock, Missing: []string{}, Stale: []string{}, Mismatched: []string{}}
+	for id, document := range ledger {
+		report.Checked++
+		indexedDocument, ok := indexed[id]
+		if !ok {
+			report.Missing = append(report.Missing, id)
+			continue
+		}
+
+		ledgerAsset, err := decodeIndexedAsset(id, document)
+		if err != nil {
+			return nil, err
+		}
+		indexedAsset, err := decodeIndexedAsset(id, indexedDocument)
+		if err != nil || !reflect.DeepEqual(ledgerAsset, indexedAsset) {
+			report.Mismatched = append(report.Mismatched, id)
+		}
+	}
+	for id := range indexed {
+		if _, ok := ledger[id]; !ok {
+			report.Stale = append(report.Stale, id)
+		}
+	}
+	sort.Strings(report.Missing)
+	sort.Strings(report.Stale)
+	sort.Strings(report.Mismatched)
+
+	if fix && !report.Consistent() {
+		if err := index.repair(ledger, report); err != nil {
+			return nil, err
+		}
+		report.Fixed = true
+	}
+
+	return report, nil
+}
+
+// ledgerDocuments reads every asset from the world state a page at a time, keyed by asset ID
+func (setup *OrgSetup) ledgerDocuments() (map[string][]byte, error) {
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	documents := make(map[string][]byte)
+	bookmark := ""
+	for {
+		result, err := contract.EvaluateTransaction("GetAssetsWithPagination", reconcilePageSize, bookmark)
+		if err != nil {
+			return nil, fmt.Errorf("error querying GetAssetsWithPagination: %w", err)
+		}
+
+		var page struct {
+			Records             []json.RawMessage `json:"Records"`
+			FetchedRecordsCount int32             `json:"FetchedRecordsCount"`
+			Bookmark            string            `json:"Bookmark"`
+		}
+		if err := json.Unmarshal(result, &page); err != nil {
+			return nil, err
+		}
+		for _, record := range page.Records {
+			var asset struct {
+				ID string `json:"ID"`
+			}
+			if err := json.Unmarshal(record, &asset); err != nil {
+				return nil, err
+			}
+			documents[asset.ID] = record
+		}
+
+		if len(page.Records) == 0 || page.Bookmark == "" || page.Bookmark == bookmark {
+			return documents, nil
+		}
+		bookmark = page.Bookmark
+	}
+}
@@ -0,0 +1,104 @@
+package web
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name           string
+		indexed        []*assetWrite
+		ledger         map[string][]byte
+		wantMissing    []string
+		wantStale      []string
+		wantMismatched []string
+	}{
+		{
+			name:    "index matches the ledger",
+			indexed: []*assetWrite{{AssetID: "A", TxID: "tx1", Value: assetDocument("A", "Harvested", 100)}},
+			ledger:  map[string][]byte{"A": assetDocument("A", "Harvested", 100)},
+		},
+		{
+			name:    "fields outside the index are ignored",
+			indexed: []*assetWrite{{AssetID: "A", TxID: "tx1", Value: assetDocument("A", "Harvested", 100)}},
+			ledger:  map[string][]byte{"A": []byte(`{"ID": "A", "FarmerId": "farmer-1", "Variety": "Roma", "Status": "Harvested", "Quantity": 100, "Unit": "kg", "PriceHash": "ab12"}`)},
+		},
+		{
+			name:        "asset missing from the index",
+			indexed:     []*assetWrite{{AssetID: "A", TxID: "tx1", Value: assetDocument("A", "Harvested", 100)}},
+			ledger:      map[string][]byte{"A": assetDocument("A", "Harvested", 100), "B": assetDocument("B", "Harvested", 50)},
+			wantMissing: []string{"B"},
+		},
+		{
+			name: "asset no longer on the ledger",
+			indexed: []*assetWrite{
+				{AssetID: "A", TxID: "tx1", Value: assetDocument("A", "Harvested", 100)},
+				{AssetID: "B", TxID: "tx1", Value: assetDocument("B", "Harvested", 50)},
+			},
+			ledger:    map[string][]byte{"A": assetDocument("A", "Harvested", 100)},
+			wantStale: []string{"B"},
+		},
+		{
+			name: "indexed fields differ",
+			indexed: []*assetWrite{
+				{AssetID: "A", TxID: "tx1", Value: assetDocument("A", "Harvested", 100)},
+				{AssetID: "B", TxID: "tx1", Value: assetDocument("B", "Harvested", 50)},
+			},
+			ledger:         map[string][]byte{"A": assetDocument("A", "WithWholesaler", 100), "B": assetDocument("B", "Harvested", 20)},
+			wantMismatched: []string{"A", "B"},
+		},
+		{
+			name:           "legacy document on the ledger",
+			indexed:        []*assetWrite{{AssetID: "A", TxID: "tx1", Value: assetDocument("A", "Harvested", 100)}},
+			ledger:         map[string][]byte{"A": []byte(`{"ID": "A", "FarmerId": "farmer-1", "Status": "Harvested", "Quantity": "100kg"}`)},
+			wantMismatched: []string{"A"},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, fix := range []bool{false, true} {
+			name := tt.name
+			if fix {
+				name += " with fix"
+			}
+			t.Run(name, func(t *testing.T) {
+				index := testIndex(t)
+				if err := index.ApplyBlock(3, tt.indexed); err != nil {
+					t.Fatal(err)
+				}
+
+				report, err := index.reconcile(tt.ledger, fix)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if report.LastBlock != 3 || report.Checked != len(tt.ledger) {
+					t.Errorf("got last block %d and %d checked, want 3 and %d", report.LastBlock, report.Checked, len(tt.ledger))
+				}
+				for _, diff := range []struct {
+					kind      string
+					got, want []string
+				}{
+					{"missing", report.Missing, tt.wantMissing},
+					{"stale", report.Stale, tt.wantStale},
+					{"mismatched", report.Mismatched, tt.wantMismatched},
+				} {
+					if len(diff.got) != len(diff.want) || (len(diff.want) > 0 && !reflect.DeepEqual(diff.got, diff.want)) {
+						t.Errorf("got %s %v, want %v", diff.kind, diff.got, diff.want)
+					}
+				}
+				if report.Fixed != (fix && !report.Consistent()) {
+					t.Errorf("got fixed %t", report.Fixed)
+				}
+
+				again, err := index.reconcile(tt.ledger, false)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if again.Consistent() != (fix || report.Consistent()) {
+					t.Errorf("a second run found missing %v, stale %v and mismatched %v", again.Missing, again.Stale, again.Mismatched)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// GetAssetHistory returns every modification of the asset ?id= with the MSPID of the organization that
+// submitted it. Modifications written before the chaincode recorded writers have an empty MSPID.
+func (setup *OrgSetup) GetAssetHistory(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Get Asset History request")
+
+	// Extract 'id' from query parameters
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Query parameter 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Evaluate transaction using the GetAssetHistory function from chaincode
+	result, err := contract.EvaluateTransaction("GetAssetHistory", id)
+	if err != nil {
+		http.Error(w, "Error querying GetAssetHistory: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// An asset without history is returned as an empty list
+	var data []interface{}
+	if len(result) > 0 {
+		if err := json.Unmarshal(result, &data); err != nil {
+			http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if data == nil {
+		data = []interface{}{}
+	}
+
+	// Send the response with data
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
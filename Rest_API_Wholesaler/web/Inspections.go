@@ -0,0 +1,106 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Inspections lists the quality inspections of an asset on GET ?id= and records a new inspection,
+// made by this organization, on POST
+func (setup *OrgSetup) Inspections(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Inspections request")
+
+	switch r.Method {
+	case http.MethodGet:
+		setup.getInspections(w, r)
+	case http.MethodPost:
+		setup.addInspection(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (setup *OrgSetup) getInspections(w http.ResponseWriter, r *http.Request) {
+	// Extract 'id' from query parameters
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Query parameter 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Evaluate transaction using the GetInspections function from chaincode
+	result, err := contract.EvaluateTransaction("GetInspections", id)
+	if err != nil {
+		http.Error(w, "Error querying GetInspections: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var data []interface{}
+	if len(result) > 0 {
+		if err := json.Unmarshal(result, &data); err != nil {
+			http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if data == nil {
+		data = []interface{}{}
+	}
+
+	// Send the response with data
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+func (setup *OrgSetup) addInspection(w http.ResponseWriter, r *http.Request) {
+	// Define a structure for the expected JSON payload
+	type Request struct {
+		ID               string   `json:"id"`
+		Grade            string   `json:"grade,omitempty"`
+		Brix             *float64 `json:"brix,omitempty"`
+		Firmness         *float64 `json:"firmness,omitempty"`
+		DefectPercent    *float64 `json:"defectPercent,omitempty"`
+		PesticideResidue *float64 `json:"pesticideResidue,omitempty"`
+		Notes            string   `json:"notes,omitempty"`
+	}
+
+	var requestData Request
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if requestData.ID == "" {
+		http.Error(w, "Field 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	payload, err := json.Marshal(requestData)
+	if err != nil {
+		http.Error(w, "JSON Marshal error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Submit transaction to the ledger to record the inspection
+	result, err := contract.SubmitTransaction("AddInspection", string(payload))
+	if err != nil {
+		http.Error(w, "Error invoking AddInspection: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var inspection interface{}
+	if err := json.Unmarshal(result, &inspection); err != nil {
+		http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with the recorded inspection, including any threshold breaches
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(inspection)
+}
@@ -0,0 +1,48 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+func (setup *OrgSetup) MergeAssets(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received MergeAssets request")
+
+	// Define a structure for the expected JSON payload
+	type Request struct {
+		ID        string   `json:"id"`
+		SourceIDs []string `json:"sourceIds"`
+	}
+
+	var requestData Request
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if requestData.ID == "" {
+		http.Error(w, "Field 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	payload, err := json.Marshal(requestData)
+	if err != nil {
+		http.Error(w, "JSON Marshal error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Submit transaction to the ledger to merge the source assets into a new one
+	_, err = contract.SubmitTransaction("MergeAssets", string(payload))
+	if err != nil {
+		http.Error(w, "Error invoking MergeAssets: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with the merged asset ID
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Assets merged successfully", "id": requestData.ID})
+}
@@ -0,0 +1,65 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+func (setup *OrgSetup) QueryAssets(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Query Assets request")
+
+	// The request body is a CouchDB query or selector, passed through to the chaincode
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !json.Valid(body) {
+		http.Error(w, "Request body must be a JSON query or selector", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Page through the query results when the client asks for it
+	pageSize, bookmark, paginated, err := paginationParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if paginated {
+		result, err := contract.EvaluateTransaction("QueryAssetsWithPagination", string(body), pageSize, bookmark)
+		if err != nil {
+			http.Error(w, "Error querying QueryAssetsWithPagination: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writePage(w, result)
+		return
+	}
+
+	// Evaluate transaction using the QueryAssets function from chaincode
+	result, err := contract.EvaluateTransaction("QueryAssets", string(body))
+	if err != nil {
+		http.Error(w, "Error querying QueryAssets: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// No matching assets are returned as an empty list
+	var data []interface{}
+	if len(result) > 0 {
+		if err := json.Unmarshal(result, &data); err != nil {
+			http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if data == nil {
+		data = []interface{}{}
+	}
+
+	// Send the response with data
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
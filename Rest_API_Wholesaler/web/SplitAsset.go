@@ -0,0 +1,56 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+func (setup *OrgSetup) SplitAsset(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received SplitAsset request")
+
+	// Define a structure for the expected JSON payload
+	type SubLot struct {
+		ID       string  `json:"id"`
+		Quantity float64 `json:"quantity"`
+	}
+	type Request struct {
+		ID       string   `json:"id"`
+		Children []SubLot `json:"children"`
+	}
+
+	var requestData Request
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if requestData.ID == "" {
+		http.Error(w, "Field 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	payload, err := json.Marshal(requestData)
+	if err != nil {
+		http.Error(w, "JSON Marshal error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Submit transaction to the ledger to split the asset into sub-lots
+	_, err = contract.SubmitTransaction("SplitAsset", string(payload))
+	if err != nil {
+		http.Error(w, "Error invoking SplitAsset: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with the new sub-lot IDs
+	ids := make([]string, 0, len(requestData.Children))
+	for _, child := range requestData.Children {
+		ids = append(ids, child.ID)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": "Asset split successfully", "id": requestData.ID, "children": ids})
+}
@@ -0,0 +1,102 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// Telemetry lists the cold-chain telemetry summaries of an asset on GET ?id= and ingests a batch of sensor
+// readings for it on POST ?id=
+func (setup *OrgSetup) Telemetry(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("Received Telemetry request")
+
+	switch r.Method {
+	case http.MethodGet:
+		setup.getTelemetry(w, r)
+	case http.MethodPost:
+		setup.recordTelemetry(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (setup *OrgSetup) getTelemetry(w http.ResponseWriter, r *http.Request) {
+	// Extract 'id' from query parameters
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Query parameter 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Evaluate transaction using the GetTelemetry function from chaincode
+	result, err := contract.EvaluateTransaction("GetTelemetry", id)
+	if err != nil {
+		http.Error(w, "Error querying GetTelemetry: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var data []interface{}
+	if len(result) > 0 {
+		if err := json.Unmarshal(result, &data); err != nil {
+			http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if data == nil {
+		data = []interface{}{}
+	}
+
+	// Send the response with data
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+// recordTelemetry passes the request body, a batch of the form {"deviceId": ..., "readings": [{"timestamp",
+// "temperature", "humidity"}]}, to the chaincode in the transient map. The readings never reach the ledger;
+// the stored BatchHash is the SHA-256 of the body exactly as uploaded, so the logger file can be checked
+// against it later.
+func (setup *OrgSetup) recordTelemetry(w http.ResponseWriter, r *http.Request) {
+	// Extract 'id' from query parameters
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Query parameter 'id' is missing", http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !json.Valid(body) {
+		http.Error(w, "JSON Decode error: request body is not valid JSON", http.StatusBadRequest)
+		return
+	}
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Submit transaction to the ledger to record the telemetry summary
+	result, err := contract.Submit("RecordTelemetry", client.WithArguments(id), client.WithTransient(map[string][]byte{"telemetry": body}))
+	if err != nil {
+		http.Error(w, "Error invoking RecordTelemetry: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var summary interface{}
+	if err := json.Unmarshal(result, &summary); err != nil {
+		http.Error(w, "Error unmarshaling JSON data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send the response with the stored summary, including whether the limits were breached
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
@@ -3,6 +3,7 @@ package web
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 )
 
@@ -17,43 +18,43 @@ func (setup *OrgSetup) WholesalerUpdateAsset(w http.ResponseWriter, r *http.Requ
 		WholesalerBuyDate string `json:"wholesalerBuyDate"`
 	}
 
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	var requestData Request
-	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+	if err := json.Unmarshal(body, &requestData); err != nil {
 		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	network := setup.Gateway.GetNetwork(setup.Channel)
-	contract := network.GetContract(setup.Chaincode)
-
-	// Retrieve existing asset data
-	result, err := contract.EvaluateTransaction("ReadAsset", requestData.ID)
+	// The price, if any, travels in the transient map rather than the public payload
+	transient, err := priceTransient(body)
 	if err != nil {
-		http.Error(w, "Error invoking ReadAsset: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "JSON Decode error: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Unmarshal the asset data to update it
-	var asset map[string]string
-	if err := json.Unmarshal(result, &asset); err != nil {
-		http.Error(w, "JSON Unmarshal error: "+err.Error(), http.StatusInternalServerError)
+	if requestData.ID == "" {
+		http.Error(w, "Field 'id' is missing", http.StatusBadRequest)
 		return
 	}
 
-	// Update the asset with the new wholesaler information
-	if requestData.WholesalerId != "" {
-		asset["WholesalerId"] = requestData.WholesalerId
-	}
-	if requestData.WholesalerName != "" {
-		asset["WholesalerName"] = requestData.WholesalerName
-	}
-	if requestData.WholesalerBuyDate != "" {
-		asset["WholesalerBuyDate"] = requestData.WholesalerBuyDate
+	payload, err := json.Marshal(requestData)
+	if err != nil {
+		http.Error(w, "JSON Marshal error: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
-	// Submit transaction to the ledger to update the asset
-	_, err = contract.SubmitTransaction("UpdateAsset", requestData.ID, asset["FarmerId"], asset["FarmLocation"], asset["Variety"], asset["BatchNo"], asset["HarvestDate"], asset["Price"], asset["Quantity"], asset["WholesalerId"], asset["WholesalerPrice"], asset["WholesalerBuyDate"], asset["RetailerId"], asset["RetailerBuyDate"])
+
+	network := setup.Gateway.GetNetwork(setup.Channel)
+	contract := network.GetContract(setup.Chaincode)
+
+	// Submit transaction to the ledger to merge the wholesaler purchase into the asset
+	_, err = contract.Submit("RecordWholesalePurchase", priceOptions(string(payload), transient, "Org1MSP", "Org2MSP")...)
 	if err != nil {
-		http.Error(w, "Error invoking UpdateAsset: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Error invoking RecordWholesalePurchase: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -22,6 +22,12 @@ type OrgSetup struct {
 	Gateway      client.Gateway
 	Chaincode    string
 	Channel      string
+	// CheckpointPath is the file where event listeners record how far they have read
+	CheckpointPath string
+	Checkpoints    *CheckpointStore
+	// IndexPath is the SQLite file of the off-chain asset index; the index is disabled when it is empty
+	IndexPath string
+	Index     *AssetIndex
 }
 
 var clientsMutex sync.Mutex
@@ -32,8 +38,38 @@ func Serve(setups OrgSetup) {
 
 	// Define routes for direct endpoints
 	mux.HandleFunc("/wholeSalerUpdate", setups.WholesalerUpdateAsset)
+	mux.HandleFunc("/split", setups.SplitAsset)
+	mux.HandleFunc("/merge", setups.MergeAssets)
+	mux.HandleFunc("/participants", setups.Participants)
+	mux.HandleFunc("/shipments", setups.Shipments)
+	mux.HandleFunc("/shipments/dispatch", setups.DispatchShipment)
+	mux.HandleFunc("/shipments/cancel", setups.CancelShipment)
+	mux.HandleFunc("/shipments/receive", setups.ReceiveShipment)
+	mux.HandleFunc("/transfers", setups.GetTransferOffers)
+	mux.HandleFunc("/transfers/offer", setups.OfferTransfer)
+	mux.HandleFunc("/transfers/accept", setups.AcceptTransfer)
+	mux.HandleFunc("/transfers/reject", setups.RejectTransfer)
 	mux.HandleFunc("/getAll", setups.GetAllAssets)
 	mux.HandleFunc("/getEntry", setups.ReadAsset)
+	mux.HandleFunc("/history", setups.GetAssetHistory)
+	mux.HandleFunc("/prices", setups.GetAssetPrices)
+	mux.HandleFunc("/prices/query", setups.QueryPrices)
+	mux.HandleFunc("/lineage", setups.GetLineage)
+	mux.HandleFunc("/inspections", setups.Inspections)
+	mux.HandleFunc("/telemetry", setups.Telemetry)
+	mux.HandleFunc("/recallImpact", setups.GetRecallImpact)
+	mux.HandleFunc("/inventory", setups.GetInventory)
+	mux.HandleFunc("/getBy", setups.GetAssetsBy)
+	mux.HandleFunc("/query", setups.QueryAssets)
+	mux.HandleFunc("/events", setups.StreamEvents)
+	mux.HandleFunc("/index/search", setups.SearchIndex)
+	mux.HandleFunc("/index/stats", setups.GetIndexStats)
+	mux.HandleFunc("/index/suppliers", setups.GetSuppliers)
+
+	// Keep the off-chain asset index in sync with the ledger
+	if setups.Index != nil {
+		setups.startIndexSync()
+	}
 
 	// Wrap the mux with the logging middleware
 	loggedMux := loggingMiddleware(mux)
@@ -0,0 +1,127 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// CheckpointStore persists the position of each named event listener in a JSON file, so listeners resume
+// where they stopped when the REST service restarts.
+type CheckpointStore struct {
+	path      string
+	mutex     sync.Mutex
+	positions map[string]checkpointPosition
+}
+
+// checkpointPosition is the block in which a listener expects its next event and the last transaction in
+// that block it has processed
+type checkpointPosition struct {
+	BlockNumber   uint64 `json:"blockNumber"`
+	TransactionID string `json:"transactionId"`
+}
+
+// OpenCheckpointStore loads the checkpoint file at path, starting with no positions if it does not exist yet
+func OpenCheckpointStore(path string) (*CheckpointStore, error) {
+	store := &CheckpointStore{path: path, positions: make(map[string]checkpointPosition)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint file: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &store.positions); err != nil {
+			return nil, fmt.Errorf("failed to parse checkpoint file %s: %w", path, err)
+		}
+	}
+
+	return store, nil
+}
+
+// Checkpointer returns the checkpointer of a named listener
+func (store *CheckpointStore) Checkpointer(listener string) *Checkpointer {
+	return &Checkpointer{store: store, listener: listener}
+}
+
+func (store *CheckpointStore) position(listener string) checkpointPosition {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.positions[listener]
+}
+
+// update records a listener's position and writes the whole file through a temporary file and a rename,
+// so a crash mid-write leaves the previous checkpoints intact
+func (store *CheckpointStore) update(listener string, position checkpointPosition) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.positions[listener] = position
+	data, err := json.MarshalIndent(store.positions, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(store.path), filepath.Base(store.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint file: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed to write checkpoint file: %w", err)
+	}
+	if err := tempFile.Sync(); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed to sync checkpoint file: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("failed to close checkpoint file: %w", err)
+	}
+	if err := os.Rename(tempFile.Name(), store.path); err != nil {
+		return fmt.Errorf("failed to replace checkpoint file: %w", err)
+	}
+
+	return nil
+}
+
+// Checkpointer tracks the position of one listener in a CheckpointStore. It satisfies client.Checkpoint,
+// so it can be passed to client.WithCheckpoint to resume an event stream.
+type Checkpointer struct {
+	store    *CheckpointStore
+	listener string
+}
+
+// BlockNumber returns the block in which the next event is expected
+func (c *Checkpointer) BlockNumber() uint64 {
+	return c.store.position(c.listener).BlockNumber
+}
+
+// TransactionID returns the last processed transaction in the current block
+func (c *Checkpointer) TransactionID() string {
+	return c.store.position(c.listener).TransactionID
+}
+
+// CheckpointBlock records that a block has been processed completely
+func (c *Checkpointer) CheckpointBlock(blockNumber uint64) error {
+	return c.store.update(c.listener, checkpointPosition{BlockNumber: blockNumber + 1})
+}
+
+// CheckpointTransaction records that a transaction has been processed
+func (c *Checkpointer) CheckpointTransaction(blockNumber uint64, transactionID string) error {
+	return c.store.update(c.listener, checkpointPosition{BlockNumber: blockNumber, TransactionID: transactionID})
+}
+
+// CheckpointChaincodeEvent records that a chaincode event has been processed
+func (c *Checkpointer) CheckpointChaincodeEvent(event *client.ChaincodeEvent) error {
+	return c.CheckpointTransaction(event.BlockNumber, event.TransactionID)
+}
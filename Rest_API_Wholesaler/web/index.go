@@ -0,0 +1,474 @@
+package web
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// indexSchema creates the tables of the off-chain asset index. assets holds the latest version of each
+// asset, asset_links the split and merge edges between lots, asset_writes one row per transaction that
+// wrote an asset and sync_state the last block applied to the index.
+const indexSchema = `
+CREATE TABLE IF NOT EXISTS assets (
+	id                  TEXT PRIMARY KEY,
+	farmer_id           TEXT NOT NULL DEFAULT '',
+	farmer_name         TEXT NOT NULL DEFAULT '',
+	farm_location       TEXT NOT NULL DEFAULT '',
+	variety             TEXT NOT NULL DEFAULT '',
+	batch_no            TEXT NOT NULL DEFAULT '',
+	harvest_date        TEXT NOT NULL DEFAULT '',
+	quantity            REAL NOT NULL DEFAULT 0,
+	unit                TEXT NOT NULL DEFAULT '',
+	wholesaler_id       TEXT NOT NULL DEFAULT '',
+	wholesaler_name     TEXT NOT NULL DEFAULT '',
+	wholesaler_buy_date TEXT NOT NULL DEFAULT '',
+	retailer_id         TEXT NOT NULL DEFAULT '',
+	retailer_name       TEXT NOT NULL DEFAULT '',
+	retailer_buy_date   TEXT NOT NULL DEFAULT '',
+	status              TEXT NOT NULL DEFAULT '',
+	parent_id           TEXT NOT NULL DEFAULT '',
+	last_updated_by     TEXT NOT NULL DEFAULT '',
+	block_number        INTEGER NOT NULL,
+	tx_id               TEXT NOT NULL,
+	document            TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS assets_variety ON assets (variety);
+CREATE INDEX IF NOT EXISTS assets_status ON assets (status);
+CREATE INDEX IF NOT EXISTS assets_farmer ON assets (farmer_id);
+CREATE INDEX IF NOT EXISTS assets_wholesaler ON assets (wholesaler_id);
+CREATE INDEX IF NOT EXISTS assets_retailer ON assets (retailer_id);
+CREATE INDEX IF NOT EXISTS assets_harvest_date ON assets (harvest_date);
+
+CREATE TABLE IF NOT EXISTS asset_links (
+	from_id TEXT NOT NULL,
+	to_id   TEXT NOT NULL,
+	type    TEXT NOT NULL,
+	PRIMARY KEY (from_id, to_id)
+);
+CREATE INDEX IF NOT EXISTS asset_links_to ON asset_links (to_id);
+
+CREATE TABLE IF NOT EXISTS asset_writes (
+	tx_id        TEXT NOT NULL,
+	asset_id     TEXT NOT NULL,
+	block_number INTEGER NOT NULL,
+	msp_id       TEXT NOT NULL,
+	timestamp    TEXT NOT NULL,
+	is_delete    INTEGER NOT NULL,
+	PRIMARY KEY (tx_id, asset_id)
+);
+CREATE INDEX IF NOT EXISTS asset_writes_asset ON asset_writes (asset_id);
+
+CREATE TABLE IF NOT EXISTS sync_state (
+	id         INTEGER PRIMARY KEY CHECK (id = 1),
+	last_block INTEGER NOT NULL
+);
+`
+
+// AssetIndex is an embedded SQLite copy of the asset world state, kept in sync from block events so
+// searches, aggregations and joins do not load the peers
+type AssetIndex struct {
+	db *sql.DB
+}
+
+// indexedAsset holds the asset fields kept in the index, decoded from the JSON the chaincode writes
+type indexedAsset struct {
+	ID                string   `json:"ID"`
+	FarmerId          string   `json:"FarmerId"`
+	FarmerName        string   `json:"FarmerName"`
+	FarmLocation      string   `json:"FarmLocation"`
+	Variety           string   `json:"Variety"`
+	BatchNo           string   `json:"BatchNo"`
+	HarvestDate       string   `json:"HarvestDate"`
+	Quantity          float64  `json:"Quantity"`
+	Unit              string   `json:"Unit"`
+	WholesalerId      string   `json:"WholesalerId"`
+	WholesalerName    string   `json:"WholesalerName"`
+	WholesalerBuyDate string   `json:"WholesalerBuyDate"`
+	RetailerId        string   `json:"RetailerId"`
+	RetailerName      string   `json:"RetailerName"`
+	RetailerBuyDate   string   `json:"RetailerBuyDate"`
+	Status            string   `json:"Status"`
+	ParentID          string   `json:"ParentID"`
+	SourceIDs         []string `json:"SourceIDs"`
+	LastUpdatedBy     string   `json:"LastUpdatedBy"`
+}
+
+// assetWrite is one write to an asset key, as decoded from a valid transaction in a block
+type assetWrite struct {
+	AssetID   string
+	TxID      string
+	MSPID     string
+	Timestamp time.Time
+	IsDelete  bool
+	Value     []byte
+}
+
+// OpenAssetIndex opens the SQLite database at path, creating the index tables if needed
+func OpenAssetIndex(path string) (*AssetIndex, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open asset index: %w", err)
+	}
+	// SQLite allows a single writer; one connection keeps the sync and the handlers from contending
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(indexSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create asset index tables: %w", err)
+	}
+
+	return &AssetIndex{db: db}, nil
+}
+
+// LastBlock returns the number of the last block applied to the index, and false if none has been
+func (index *AssetIndex) LastBlock() (uint64, bool, error) {
+	var lastBlock uint64
+	err := index.db.QueryRow("SELECT last_block FROM sync_state WHERE id = 1").Scan(&lastBlock)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	return lastBlock, true, nil
+}
+
+// ApplyBlock applies the asset writes of a block in one database transaction and records the block as
+// applied. Blocks at or below the last applied block are skipped, so replaying a block after a restart
+// leaves the index unchanged.
+func (index *AssetIndex) ApplyBlock(blockNumber uint64, writes []*assetWrite) error {
+	lastBlock, applied, err := index.LastBlock()
+	if err != nil {
+		return err
+	}
+	if applied && blockNumber <= lastBlock {
+		return nil
+	}
+
+	tx, err := index.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, write := range writes {
+		if err := applyWrite(tx, blockNumber, write); err != nil {
+			return fmt.Errorf("failed to index asset %s from transaction %s: %w", write.AssetID, write.TxID, err)
+		}
+	}
+
+	_, err = tx.Exec(`INSERT INTO sync_state (id, last_block) VALUES (1, ?)
+		ON CONFLICT (id) DO UPDATE SET last_block = excluded.last_block`, blockNumber)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func applyWrite(tx *sql.Tx, blockNumber uint64, write *assetWrite) error {
+	_, err := tx.Exec(`INSERT OR IGNORE INTO asset_writes (tx_id, asset_id, block_number, msp_id, timestamp, is_delete)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		write.TxID, write.AssetID, blockNumber, write.MSPID, write.Timestamp.UTC().Format(time.RFC3339Nano), write.IsDelete)
+	if err != nil {
+		return err
+	}
+
+	if write.IsDelete {
+		if _, err := tx.Exec("DELETE FROM assets WHERE id = ?", write.AssetID); err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM asset_links WHERE to_id = ?", write.AssetID)
+		return err
+	}
+
+	asset, err := decodeIndexedAsset(write.AssetID, write.Value)
+	if err != nil {
+		return err
+	}
+
+	return upsertAsset(tx, asset, blockNumber, write.TxID, write.Value)
+}
+
+// decodeIndexedAsset reads the fields of an asset document. Documents written before quantities were
+// numeric keep only their identifying fields, and reconciliation reports them.
+func decodeIndexedAsset(id string, document []byte) (*indexedAsset, error) {
+	var asset indexedAsset
+	if err := json.Unmarshal(document, &asset); err != nil {
+		var fallback struct {
+			FarmerId string `json:"FarmerId"`
+			Status   string `json:"Status"`
+		}
+		if json.Unmarshal(document, &fallback) != nil {
+			return nil, err
+		}
+		asset = indexedAsset{FarmerId: fallback.FarmerId, Status: fallback.Status}
+	}
+	asset.ID = id
+
+	return &asset, nil
+}
+
+// upsertAsset stores the latest version of an asset and the links to the lots it was made from
+func upsertAsset(tx *sql.Tx, asset *indexedAsset, blockNumber uint64, txID string, document []byte) error {
+	_, err := tx.Exec(`INSERT INTO assets (id, farmer_id, farmer_name, farm_location, variety, batch_no, harvest_date,
+			quantity, unit, wholesaler_id, wholesaler_name, wholesaler_buy_date, retailer_id, retailer_name,
+			retailer_buy_date, status, parent_id, last_updated_by, block_number, tx_id, document)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			farmer_id = excluded.farmer_id, farmer_name = excluded.farmer_name,
+			farm_location = excluded.farm_location, variety = excluded.variety, batch_no = excluded.batch_no,
+			harvest_date = excluded.harvest_date, quantity = excluded.quantity, unit = excluded.unit,
+			wholesaler_id = excluded.wholesaler_id, wholesaler_name = excluded.wholesaler_name,
+			wholesaler_buy_date = excluded.wholesaler_buy_date, retailer_id = excluded.retailer_id,
+			retailer_name = excluded.retailer_name, retailer_buy_date = excluded.retailer_buy_date,
+			status = excluded.status, parent_id = excluded.parent_id, last_updated_by = excluded.last_updated_by,
+			block_number = excluded.block_number, tx_id = excluded.tx_id, document = excluded.document`,
+		asset.ID, asset.FarmerId, asset.FarmerName, asset.FarmLocation, asset.Variety, asset.BatchNo, asset.HarvestDate,
+		asset.Quantity, asset.Unit, asset.WholesalerId, asset.WholesalerName, asset.WholesalerBuyDate, asset.RetailerId,
+		asset.RetailerName, asset.RetailerBuyDate, asset.Status, asset.ParentID, asset.LastUpdatedBy,
+		blockNumber, txID, string(document))
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM asset_links WHERE to_id = ?", asset.ID); err != nil {
+		return err
+	}
+	if asset.ParentID != "" {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO asset_links (from_id, to_id, type) VALUES (?, ?, 'split')", asset.ParentID, asset.ID); err != nil {
+			return err
+		}
+	}
+	for _, sourceID := range asset.SourceIDs {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO asset_links (from_id, to_id, type) VALUES (?, ?, 'merge')", sourceID, asset.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AssetSearch holds the filters of an index search. Empty filters match every asset.
+type AssetSearch struct {
+	Variety      string
+	Status       string
+	FarmerId     string
+	WholesalerId string
+	RetailerId   string
+	BatchNo      string
+	HarvestFrom  string
+	HarvestTo    string
+	Text         string
+	Limit        int
+	Offset       int
+}
+
+// Search returns the assets matching a search, ordered by ID. Text matches farmer, wholesaler and retailer
+// names and the farm location.
+func (index *AssetIndex) Search(search *AssetSearch) ([]json.RawMessage, error) {
+	var conditions []string
+	var args []interface{}
+	for _, filter := range []struct{ column, value string }{
+		{"variety", search.Variety},
+		{"status", search.Status},
+		{"farmer_id", search.FarmerId},
+		{"wholesaler_id", search.WholesalerId},
+		{"retailer_id", search.RetailerId},
+		{"batch_no", search.BatchNo},
+	} {
+		if filter.value != "" {
+			conditions = append(conditions, filter.column+" = ?")
+			args = append(args, filter.value)
+		}
+	}
+	if search.HarvestFrom != "" {
+		conditions = append(conditions, "harvest_date >= ?")
+		args = append(args, search.HarvestFrom)
+	}
+	if search.HarvestTo != "" {
+		conditions = append(conditions, "harvest_date <= ?")
+		args = append(args, search.HarvestTo)
+	}
+	if search.Text != "" {
+		conditions = append(conditions, "(farmer_name LIKE ? OR wholesaler_name LIKE ? OR retailer_name LIKE ? OR farm_location LIKE ?)")
+		pattern := "%" + search.Text + "%"
+		args = append(args, pattern, pattern, pattern, pattern)
+	}
+
+	query := "SELECT document FROM assets"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id LIMIT ? OFFSET ?"
+	args = append(args, search.Limit, search.Offset)
+
+	rows, err := index.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	documents := []json.RawMessage{}
+	for rows.Next() {
+		var document string
+		if err := rows.Scan(&document); err != nil {
+			return nil, err
+		}
+		documents = append(documents, json.RawMessage(document))
+	}
+
+	return documents, rows.Err()
+}
+
+// statsColumns maps the groupings offered by Stats to index columns
+var statsColumns = map[string]string{
+	"variety":      "variety",
+	"status":       "status",
+	"farmerId":     "farmer_id",
+	"wholesalerId": "wholesaler_id",
+	"retailerId":   "retailer_id",
+	"harvestMonth": "substr(harvest_date, 1, 7)",
+}
+
+// AssetStats is the count and total quantity of one group of assets
+type AssetStats struct {
+	Group         string  `json:"group"`
+	Unit          string  `json:"unit"`
+	AssetCount    int     `json:"assetCount"`
+	TotalQuantity float64 `json:"totalQuantity"`
+}
+
+// Stats counts assets and totals their quantity per group and unit. Lots consumed by splits and merges are
+// left out, since their quantity lives on in the lots made from them.
+func (index *AssetIndex) Stats(groupBy string) ([]*AssetStats, error) {
+	column, ok := statsColumns[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("cannot group by %q", groupBy)
+	}
+
+	rows, err := index.db.Query(`SELECT ` + column + `, unit, COUNT(*), COALESCE(SUM(quantity), 0)
+		FROM assets WHERE status <> 'Consumed'
+		GROUP BY 1, 2 ORDER BY 1, 2`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []*AssetStats{}
+	for rows.Next() {
+		var group AssetStats
+		if err := rows.Scan(&group.Group, &group.Unit, &group.AssetCount, &group.TotalQuantity); err != nil {
+			return nil, err
+		}
+		stats = append(stats, &group)
+	}
+
+	return stats, rows.Err()
+}
+
+// SupplierStats is the quantity a farmer's harvest lots contributed to a retailer's lots
+type SupplierStats struct {
+	FarmerId   string  `json:"farmerId"`
+	FarmerName string  `json:"farmerName"`
+	Unit       string  `json:"unit"`
+	LotCount   int     `json:"lotCount"`
+	Quantity   float64 `json:"quantity"`
+}
+
+// Suppliers joins a retailer's lots back through splits and merges to the original harvest lots and
+// totals, per farmer, the quantity of the retailer's lots each farmer's harvest went into. A lot merged
+// from several farmers counts towards each of them.
+func (index *AssetIndex) Suppliers(retailerId string) ([]*SupplierStats, error) {
+	rows, err := index.db.Query(`
+		WITH RECURSIVE lineage (lot_id, ancestor_id) AS (
+			SELECT id, id FROM assets WHERE retailer_id = ? AND status <> 'Consumed'
+			UNION
+			SELECT lineage.lot_id, asset_links.from_id
+			FROM lineage JOIN asset_links ON asset_links.to_id = lineage.ancestor_id
+		)
+		SELECT supplied.farmer_id, MAX(supplied.farmer_name), lot.unit, COUNT(*), SUM(lot.quantity)
+		FROM (
+			SELECT lineage.lot_id, origin.farmer_id, MAX(origin.farmer_name) AS farmer_name
+			FROM lineage JOIN assets origin ON origin.id = lineage.ancestor_id
+			WHERE NOT EXISTS (SELECT 1 FROM asset_links WHERE asset_links.to_id = origin.id)
+			GROUP BY lineage.lot_id, origin.farmer_id
+		) AS supplied
+		JOIN assets lot ON lot.id = supplied.lot_id
+		GROUP BY supplied.farmer_id, lot.unit
+		ORDER BY supplied.farmer_id, lot.unit`, retailerId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suppliers := []*SupplierStats{}
+	for rows.Next() {
+		var supplier SupplierStats
+		if err := rows.Scan(&supplier.FarmerId, &supplier.FarmerName, &supplier.Unit, &supplier.LotCount, &supplier.Quantity); err != nil {
+			return nil, err
+		}
+		suppliers = append(suppliers, &supplier)
+	}
+
+	return suppliers, rows.Err()
+}
+
+// indexedDocuments returns the stored document of every indexed asset, keyed by asset ID
+func (index *AssetIndex) indexedDocuments() (map[string][]byte, error) {
+	rows, err := index.db.Query("SELECT id, document FROM assets")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	documents := make(map[string][]byte)
+	for rows.Next() {
+		var id, document string
+		if err := rows.Scan(&id, &document); err != nil {
+			return nil, err
+		}
+		documents[id] = []byte(document)
+	}
+
+	return documents, rows.Err()
+}
+
+// repair rewrites the assets a reconciliation found missing or mismatched from their ledger documents and
+// removes the stale ones. Repaired rows keep the last applied block, since the ledger query does not say
+// which transaction wrote them.
+func (index *AssetIndex) repair(ledger map[string][]byte, report *ReconcileReport) error {
+	tx, err := index.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, ids := range [][]string{report.Missing, report.Mismatched} {
+		for _, id := range ids {
+			asset, err := decodeIndexedAsset(id, ledger[id])
+			if err != nil {
+				return err
+			}
+			if err := upsertAsset(tx, asset, report.LastBlock, "reconcile", ledger[id]); err != nil {
+				return err
+			}
+		}
+	}
+	for _, id := range report.Stale {
+		if _, err := tx.Exec("DELETE FROM assets WHERE id = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM asset_links WHERE to_id = ?", id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
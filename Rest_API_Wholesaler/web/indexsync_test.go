@@ -0,0 +1,271 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go-apiv2/msp"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testChaincode is the chaincode the test setup indexes
+const testChaincode = "toma-trace"
+
+// testTransaction describes one transaction of a test block
+type testTransaction struct {
+	txID       string
+	mspID      string
+	headerType common.HeaderType
+	namespace  string
+	writes     []*kvrwset.KVWrite
+	invalid    bool
+}
+
+// testBlock builds a block holding the given transactions, marking each valid unless it says otherwise
+func testBlock(t *testing.T, number uint64, transactions ...*testTransaction) *common.Block {
+	t.Helper()
+	block := &common.Block{
+		Header:   &common.BlockHeader{Number: number},
+		Data:     &common.BlockData{},
+		Metadata: &common.BlockMetadata{Metadata: make([][]byte, common.BlockMetadataIndex_TRANSACTIONS_FILTER+1)},
+	}
+
+	var validationCodes []byte
+	for _, transaction := range transactions {
+		block.Data.Data = append(block.Data.Data, testEnvelope(t, transaction))
+		code := peer.TxValidationCode_VALID
+		if transaction.invalid {
+			code = peer.TxValidationCode_MVCC_READ_CONFLICT
+		}
+		validationCodes = append(validationCodes, byte(code))
+	}
+	block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = validationCodes
+
+	return block
+}
+
+// testEnvelope encodes a transaction the way the orderer delivers it
+func testEnvelope(t *testing.T, transaction *testTransaction) []byte {
+	t.Helper()
+	namespace := transaction.namespace
+	if namespace == "" {
+		namespace = testChaincode
+	}
+	kvSet := mustMarshal(t, &kvrwset.KVRWSet{Writes: transaction.writes})
+	results := mustMarshal(t, &rwset.TxReadWriteSet{NsRwset: []*rwset.NsReadWriteSet{{Namespace: namespace, Rwset: kvSet}}})
+	response := mustMarshal(t, &peer.ProposalResponsePayload{Extension: mustMarshal(t, &peer.ChaincodeAction{Results: results})})
+	action := mustMarshal(t, &peer.ChaincodeActionPayload{Action: &peer.ChaincodeEndorsedAction{ProposalResponsePayload: response}})
+	data := mustMarshal(t, &peer.Transaction{Actions: []*peer.TransactionAction{{Payload: action}}})
+
+	headerType := transaction.headerType
+	if headerType == 0 {
+		headerType = common.HeaderType_ENDORSER_TRANSACTION
+	}
+	channelHeader := mustMarshal(t, &common.ChannelHeader{
+		Type:      int32(headerType),
+		TxId:      transaction.txID,
+		Timestamp: timestamppb.New(time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)),
+	})
+	signatureHeader := mustMarshal(t, &common.SignatureHeader{Creator: mustMarshal(t, &msp.SerializedIdentity{Mspid: transaction.mspID})})
+	payload := mustMarshal(t, &common.Payload{
+		Header: &common.Header{ChannelHeader: channelHeader, SignatureHeader: signatureHeader},
+		Data:   data,
+	})
+
+	return mustMarshal(t, &common.Envelope{Payload: payload})
+}
+
+func mustMarshal(t *testing.T, message proto.Message) []byte {
+	t.Helper()
+	bytes, err := proto.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes
+}
+
+// testIndex opens an empty asset index in a temporary directory
+func testIndex(t *testing.T) *AssetIndex {
+	t.Helper()
+	index, err := OpenAssetIndex(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { index.db.Close() })
+	return index
+}
+
+// assetDocument is an asset as the chaincode writes it
+func assetDocument(id, status string, quantity float64) []byte {
+	return []byte(fmt.Sprintf(`{"ID": %q, "FarmerId": "farmer-1", "Variety": "Roma", "Status": %q, "Quantity": %g, "Unit": "kg"}`, id, status, quantity))
+}
+
+func TestAssetWrites(t *testing.T) {
+	tests := []struct {
+		name         string
+		transactions []*testTransaction
+		want         []string
+	}{
+		{
+			name: "asset writes of a valid transaction",
+			transactions: []*testTransaction{{txID: "tx1", mspID: "Org1MSP", writes: []*kvrwset.KVWrite{
+				{Key: "A", Value: assetDocument("A", "Harvested", 100)},
+				{Key: "B", IsDelete: true},
+			}}},
+			want: []string{"A tx1 Org1MSP write", "B tx1 Org1MSP delete"},
+		},
+		{
+			name: "composite keys are skipped",
+			transactions: []*testTransaction{{txID: "tx1", mspID: "Org1MSP", writes: []*kvrwset.KVWrite{
+				{Key: compositeKeyNamespace + "farmer~asset\x00farmer-1\x00A\x00", Value: []byte{0}},
+				{Key: "A", Value: assetDocument("A", "Harvested", 100)},
+			}}},
+			want: []string{"A tx1 Org1MSP write"},
+		},
+		{
+			name: "invalid transactions are skipped",
+			transactions: []*testTransaction{
+				{txID: "tx1", mspID: "Org1MSP", invalid: true, writes: []*kvrwset.KVWrite{{Key: "A", Value: assetDocument("A", "Harvested", 100)}}},
+				{txID: "tx2", mspID: "Org2MSP", writes: []*kvrwset.KVWrite{{Key: "B", Value: assetDocument("B", "Harvested", 50)}}},
+			},
+			want: []string{"B tx2 Org2MSP write"},
+		},
+		{
+			name: "other chaincodes are skipped",
+			transactions: []*testTransaction{
+				{txID: "tx1", mspID: "Org1MSP", namespace: "_lifecycle", writes: []*kvrwset.KVWrite{{Key: "A", Value: []byte("{}")}}},
+			},
+		},
+		{
+			name: "configuration transactions are skipped",
+			transactions: []*testTransaction{
+				{txID: "tx1", mspID: "Org1MSP", headerType: common.HeaderType_CONFIG, writes: []*kvrwset.KVWrite{{Key: "A", Value: []byte("{}")}}},
+			},
+		},
+	}
+
+	setup := &OrgSetup{Chaincode: testChaincode}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writes, err := setup.assetWrites(testBlock(t, 5, tt.transactions...))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, write := range writes {
+				kind := "write"
+				if write.IsDelete {
+					kind = "delete"
+				}
+				got = append(got, write.AssetID+" "+write.TxID+" "+write.MSPID+" "+kind)
+				if !write.Timestamp.Equal(time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)) {
+					t.Errorf("got timestamp %s on %s", write.Timestamp, write.AssetID)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got writes %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyBlock(t *testing.T) {
+	tests := []struct {
+		name          string
+		blocks        [][]*assetWrite
+		startBlock    uint64
+		wantIDs       []string
+		wantStatus    map[string]string
+		wantLastBlock uint64
+	}{
+		{
+			name: "writes are indexed",
+			blocks: [][]*assetWrite{
+				{{AssetID: "A", TxID: "tx1", Value: assetDocument("A", "Harvested", 100)}},
+				{{AssetID: "B", TxID: "tx2", Value: assetDocument("B", "Harvested", 50)}},
+			},
+			wantIDs:       []string{"A", "B"},
+			wantStatus:    map[string]string{"A": "Harvested", "B": "Harvested"},
+			wantLastBlock: 2,
+		},
+		{
+			name: "later writes replace earlier ones",
+			blocks: [][]*assetWrite{
+				{{AssetID: "A", TxID: "tx1", Value: assetDocument("A", "Harvested", 100)}},
+				{{AssetID: "A", TxID: "tx2", Value: assetDocument("A", "WithWholesaler", 100)}},
+			},
+			wantIDs:       []string{"A"},
+			wantStatus:    map[string]string{"A": "WithWholesaler"},
+			wantLastBlock: 2,
+		},
+		{
+			name: "deletes remove the asset",
+			blocks: [][]*assetWrite{
+				{{AssetID: "A", TxID: "tx1", Value: assetDocument("A", "Harvested", 100)}, {AssetID: "B", TxID: "tx1", Value: assetDocument("B", "Harvested", 50)}},
+				{{AssetID: "A", TxID: "tx2", IsDelete: true}},
+			},
+			wantIDs:       []string{"B"},
+			wantStatus:    map[string]string{"B": "Harvested"},
+			wantLastBlock: 2,
+		},
+		{
+			name:       "replayed blocks are skipped",
+			startBlock: 7,
+			blocks: [][]*assetWrite{
+				{{AssetID: "A", TxID: "tx1", Value: assetDocument("A", "Harvested", 100)}},
+				{{AssetID: "A", TxID: "tx1", Value: assetDocument("A", "Sold", 100)}},
+			},
+			wantIDs:       []string{"A"},
+			wantStatus:    map[string]string{"A": "Harvested"},
+			wantLastBlock: 7,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index := testIndex(t)
+			for i, writes := range tt.blocks {
+				blockNumber := uint64(i + 1)
+				if tt.startBlock != 0 {
+					blockNumber = tt.startBlock
+				}
+				if err := index.ApplyBlock(blockNumber, writes); err != nil {
+					t.Fatalf("failed to apply block %d: %v", blockNumber, err)
+				}
+			}
+
+			lastBlock, applied, err := index.LastBlock()
+			if err != nil || !applied || lastBlock != tt.wantLastBlock {
+				t.Errorf("got last block %d (applied %t, error %v), want %d", lastBlock, applied, err, tt.wantLastBlock)
+			}
+			documents, err := index.Search(&AssetSearch{Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, document := range documents {
+				var asset indexedAsset
+				if err := json.Unmarshal(document, &asset); err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, asset.ID)
+				if asset.Status != tt.wantStatus[asset.ID] {
+					t.Errorf("got %s %s, want %s", asset.ID, asset.Status, tt.wantStatus[asset.ID])
+				}
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("got indexed assets %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
@@ -34,6 +34,19 @@ func Initialize(setup OrgSetup) (*OrgSetup, error) {
 		panic(err)
 	}
 	setup.Gateway = *gateway
+
+	if setup.CheckpointPath != "" {
+		setup.Checkpoints, err = OpenCheckpointStore(setup.CheckpointPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if setup.IndexPath != "" {
+		setup.Index, err = OpenAssetIndex(setup.IndexPath)
+		if err != nil {
+			return nil, err
+		}
+	}
 	log.Println("Initialization complete")
 	return &setup, nil
 }